@@ -2,50 +2,180 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/festus/microkit/breaker"
+	"github.com/festus/microkit/bulkhead"
+	"github.com/festus/microkit/hedge"
+	"github.com/festus/microkit/internal/logging"
+	"github.com/festus/microkit/metrics"
 	"github.com/festus/microkit/network"
-	"github.com/festus/microkit/internal/retry"
+	"github.com/festus/microkit/ratelimit"
+	"github.com/festus/microkit/retry"
+	"github.com/festus/microkit/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type Client struct {
-	conn *grpc.ClientConn
+	conn     *grpc.ClientConn
+	dialOpts []grpc.DialOption
+	tracer   *tracing.Tracer
+	metrics  metrics.Recorder
+	logger   *slog.Logger
+
+	retryCodes []codes.Code
+
+	tls               bool
+	tlsConfig         *tls.Config
+	caFile            string
+	certFile, keyFile string
+	block             bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTracer sets the tracer used to create client spans and propagate
+// trace context in outgoing metadata.
+func WithTracer(t *tracing.Tracer) Option {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// WithMetrics sets the recorder for call duration and status measurements.
+func WithMetrics(r metrics.Recorder) Option {
+	return func(c *Client) {
+		c.metrics = r
+	}
+}
+
+// WithLogger sets the logger for failed requests. Defaults to a logger
+// that discards everything.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithDialOptions adds options passed to grpc.NewClient.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// WithUnaryInterceptors chains interceptors around every unary RPC, the
+// first outermost. They run inside the client's retries, once per attempt.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, grpc.WithChainUnaryInterceptor(interceptors...))
+	}
+}
+
+// WithStreamInterceptors chains interceptors around every streaming RPC,
+// the first outermost.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, grpc.WithChainStreamInterceptor(interceptors...))
+	}
 }
 
-func NewClient(target string, timeout time.Duration) (*Client, error) {
-	_, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// NewClient creates a client for target. Each connection attempt gives up
+// after timeout; with WithBlock, NewClient also waits up to timeout for
+// the connection to become ready.
+func NewClient(target string, timeout time.Duration, opts ...Option) (*Client, error) {
+	c := &Client{
+		tracer:  tracing.New(),
+		metrics: metrics.Nop{},
+		logger:  logging.Discard(),
 
-	conn, err := grpc.NewClient(target,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+		retryCodes: DefaultRetryableCodes,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	creds, err := c.transportCredentials()
 	if err != nil {
 		return nil, err
 	}
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if timeout > 0 {
+		dialOpts = append(dialOpts, grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: timeout,
+		}))
+	}
+	conn, err := grpc.NewClient(target, append(dialOpts, c.dialOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
 
-	return &Client{conn: conn}, nil
+	if c.block {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if err := c.Check(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
+// The HTTP methods return network.ErrUnsupportedOperation; they exist so
+// that *Client satisfies network.Client.
+
 func (c *Client) Get(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
-	panic("HTTP methods not supported by gRPC client")
+	return nil, network.ErrUnsupportedOperation
+}
+
+func (c *Client) Head(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
+	return nil, network.ErrUnsupportedOperation
+}
+
+func (c *Client) Options(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
+	return nil, network.ErrUnsupportedOperation
 }
 
 func (c *Client) Post(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
-	panic("HTTP methods not supported by gRPC client")
+	return nil, network.ErrUnsupportedOperation
 }
 
 func (c *Client) Put(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
-	panic("HTTP methods not supported by gRPC client")
+	return nil, network.ErrUnsupportedOperation
+}
+
+func (c *Client) Patch(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
+	return nil, network.ErrUnsupportedOperation
 }
 
 func (c *Client) Delete(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
-	panic("HTTP methods not supported by gRPC client")
+	return nil, network.ErrUnsupportedOperation
 }
 
-func (c *Client) Patch(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
-	panic("HTTP methods not supported by gRPC client")
+func (c *Client) Do(ctx context.Context, req network.Request, opts ...network.Option) (*network.Response, error) {
+	return nil, network.ErrUnsupportedOperation
 }
 
 func (c *Client) Call(ctx context.Context, method string, req any, resp any, opts ...network.Option) error {
@@ -60,15 +190,194 @@ func (c *Client) Call(ctx context.Context, method string, req any, resp any, opt
 		defer cancel()
 	}
 
-	if config.RetryConfig != nil {
-		return retry.Execute(ctx, *config.RetryConfig, func() error {
-			return c.conn.Invoke(ctx, method, req, resp)
-		})
+	call := &rpc{method: method, config: config}
+	var err error
+	if config.Retry != nil {
+		err = retry.Do(ctx, config.Retry, func(ctx context.Context) error {
+			return c.retryable(c.hedgedInvoke(ctx, call, req, resp), call.trailer)
+		}, config.RetryOptions...)
+	} else {
+		err = c.hedgedInvoke(ctx, call, req, resp)
 	}
 
-	return c.conn.Invoke(ctx, method, req, resp)
+	if config.ResponseHeaders != nil {
+		*config.ResponseHeaders = headerFromMD(call.header)
+	}
+	if config.ResponseTrailers != nil {
+		*config.ResponseTrailers = headerFromMD(call.trailer)
+	}
+	return err
+}
+
+// rpc is one Call: its method and options, and the metadata the server
+// sent back on the latest attempt.
+type rpc struct {
+	method  string
+	config  *network.Config
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+// hedgedInvoke runs guardedInvoke, hedged when the call is configured for
+// it. Each copy decodes into its own message and the winner is copied into
+// resp, so resp must be a proto.Message; other responses are not hedged.
+func (c *Client) hedgedInvoke(ctx context.Context, call *rpc, req any, resp any) error {
+	call.header, call.trailer = nil, nil
+
+	m, ok := resp.(proto.Message)
+	if call.config.Hedger == nil || !ok {
+		return c.guardedInvoke(ctx, call, req, resp)
+	}
+
+	type result struct {
+		msg  proto.Message
+		call *rpc
+	}
+	winner, err := hedge.Do(ctx, call.config.Hedger, func(ctx context.Context, _ int) (result, error) {
+		attempt := &rpc{method: call.method, config: call.config}
+		out := m.ProtoReflect().New().Interface()
+		err := c.guardedInvoke(ctx, attempt, req, out)
+		return result{msg: out, call: attempt}, err
+	}, nil)
+	if winner.call != nil {
+		call.header, call.trailer = winner.call.header, winner.call.trailer
+	}
+	if err != nil {
+		return err
+	}
+	proto.Reset(m)
+	proto.Merge(m, winner.msg)
+	return nil
+}
+
+// guardedInvoke runs invoke through the call's rate limiter, bulkhead and
+// concurrency limiter and the method's circuit breaker, if any.
+func (c *Client) guardedInvoke(ctx context.Context, call *rpc, req any, resp any) error {
+	config := call.config
+	if config.RateLimiter != nil {
+		key := ratelimit.Key(ctx)
+		if key == "" {
+			key = call.method
+		}
+		if err := config.RateLimiter.Take(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if config.Bulkhead != nil {
+		release, err := config.Bulkhead.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	var permit *ratelimit.Permit
+	if config.Concurrency != nil {
+		var err error
+		if permit, err = config.Concurrency.Acquire(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if config.Breakers == nil {
+		err = c.invoke(ctx, call, req, resp)
+	} else if done, berr := config.Breakers.Get(call.method).Allow(); berr != nil {
+		err = berr
+	} else {
+		err = c.invoke(ctx, call, req, resp)
+		done(endpointFailure(err))
+	}
+
+	switch {
+	case permit == nil:
+	case errors.Is(err, breaker.ErrCircuitOpen):
+		permit.Release()
+	default:
+		permit.Done(overload(err))
+	}
+	return err
+}
+
+// overload returns err if its status code suggests the server is
+// overloaded, for concurrency limits; other errors are the application's.
+func overload(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return err
+	case codes.Canceled:
+		return context.Canceled
+	}
+	return nil
+}
+
+// endpointFailure is what a call counts as for circuit breakers and
+// outlier ejection: err if its status code suggests the server is failing
+// or overloaded, and nil for errors that are the application's, such as
+// InvalidArgument or NotFound.
+func endpointFailure(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded,
+		codes.Internal, codes.Unknown, codes.DataLoss:
+		return err
+	}
+	return nil
+}
+
+// rejected reports whether err means the call was refused locally by a
+// breaker, limiter or bulkhead, which retrying at once would not change.
+func rejected(err error) bool {
+	return errors.Is(err, breaker.ErrCircuitOpen) ||
+		errors.Is(err, bulkhead.ErrRejected) ||
+		errors.Is(err, ratelimit.ErrRateLimited) ||
+		errors.Is(err, ratelimit.ErrLimitExceeded)
+}
+
+func (c *Client) invoke(ctx context.Context, call *rpc, req any, resp any) error {
+	start := time.Now()
+	ctx, span := c.tracer.StartRPC(ctx, call.method)
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for k, v := range call.config.Headers {
+		md.Set(k, v...)
+	}
+	c.tracer.Inject(ctx, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	err := c.conn.Invoke(ctx, call.method, req, resp, grpc.Header(&call.header), grpc.Trailer(&call.trailer))
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	tracing.End(span, err)
+	c.metrics.ObserveRequest("grpc", call.method, code.String(), time.Since(start))
+	if err != nil {
+		c.logger.Warn("grpc: call failed", "method", call.method, "code", code.String(), "error", err)
+		return newStatusError(call.method, err)
+	}
+	return nil
 }
 
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
+
+// Check reports whether the connection is, or becomes, ready before ctx
+// ends. An idle connection is asked to connect first.
+func (c *Client) Check(ctx context.Context) error {
+	for {
+		state := c.conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Shutdown:
+			return errors.New("grpc: connection shut down")
+		case connectivity.Idle:
+			c.conn.Connect()
+		}
+
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("grpc: connection %s: %w", state, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/breaker"
+	"github.com/festus/microkit/network"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// startServer serves the gRPC health service behind interceptor.
+func startServer(t *testing.T, interceptor grpclib.UnaryServerInterceptor) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpclib.NewServer(grpclib.UnaryInterceptor(interceptor))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String()
+}
+
+func newTestClient(t *testing.T, addr string, opts ...Option) *Client {
+	t.Helper()
+	client, err := NewClient(addr, time.Second, opts...)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCallMetadata(t *testing.T) {
+	addr := startServer(t, func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		grpclib.SetHeader(ctx, metadata.Pairs("x-tenant-echo", md.Get("x-tenant")[0]))
+		grpclib.SetTrailer(ctx, metadata.Pairs("x-cost", "3"))
+		return handler(ctx, req)
+	})
+	client := newTestClient(t, addr)
+
+	var headers, trailers network.Header
+	err := client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{},
+		network.WithHeader("X-Tenant", "acme"), network.WithResponseMetadata(&headers, &trailers))
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if got := headers.Get("X-Tenant-Echo"); got != "acme" {
+		t.Fatalf("Expected the header to reach the server and come back, got %q", got)
+	}
+	if got := trailers.Get("X-Cost"); got != "3" {
+		t.Fatalf("Expected the trailer, got %q", got)
+	}
+}
+
+func TestUnaryInterceptorsChain(t *testing.T) {
+	addr := startServer(t, func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		return handler(ctx, req)
+	})
+
+	var order []string
+	record := func(name string) grpclib.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply any, cc *grpclib.ClientConn, invoker grpclib.UnaryInvoker, opts ...grpclib.CallOption) error {
+			order = append(order, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	client := newTestClient(t, addr, WithUnaryInterceptors(record("outer"), record("inner")))
+
+	if err := client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Fatalf("Expected outer then inner, got %v", order)
+	}
+}
+
+func TestRetryOnlyRetryableCodes(t *testing.T) {
+	var calls atomic.Int32
+	addr := startServer(t, func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		calls.Add(1)
+		st, _ := status.New(codes.InvalidArgument, "bad service name").
+			WithDetails(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_UNKNOWN})
+		return nil, st.Err()
+	})
+	client := newTestClient(t, addr)
+
+	err := client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{},
+		network.WithRetry(3, time.Millisecond, time.Millisecond, 2))
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected a *StatusError, got %T: %v", err, err)
+	}
+	if statusErr.Code != codes.InvalidArgument || statusErr.Message != "bad service name" || statusErr.Method != checkMethod {
+		t.Fatalf("Unexpected status error: %+v", statusErr)
+	}
+	if len(statusErr.Details) != 1 {
+		t.Fatalf("Expected 1 detail, got %v", statusErr.Details)
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected status.Code to see through the error, got %v", status.Code(err))
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected InvalidArgument not to be retried, got %d calls", calls.Load())
+	}
+}
+
+func TestBreakerIgnoresApplicationErrors(t *testing.T) {
+	var code atomic.Int32
+	code.Store(int32(codes.InvalidArgument))
+	addr := startServer(t, func(context.Context, any, *grpclib.UnaryServerInfo, grpclib.UnaryHandler) (any, error) {
+		return nil, status.Error(codes.Code(code.Load()), "failed")
+	})
+	client := newTestClient(t, addr)
+	set := breaker.NewSet(breaker.Config{ConsecutiveFailures: 3})
+	call := func() error {
+		return client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{},
+			network.WithCircuitBreaker(set))
+	}
+
+	for i := 0; i < 10; i++ {
+		call()
+	}
+	if state := set.Get(checkMethod).State(); state != breaker.Closed {
+		t.Fatalf("Expected InvalidArgument to leave the breaker closed, got %s", state)
+	}
+
+	code.Store(int32(codes.Unavailable))
+	for i := 0; i < 3; i++ {
+		call()
+	}
+	if err := call(); !errors.Is(err, breaker.ErrCircuitOpen) {
+		t.Fatalf("Expected Unavailable to open the breaker, got %v", err)
+	}
+}
+
+func TestRetryHonorsPushback(t *testing.T) {
+	var calls atomic.Int32
+	addr := startServer(t, func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		switch calls.Add(1) {
+		case 1:
+			grpclib.SetTrailer(ctx, metadata.Pairs(pushbackKey, "100"))
+			return nil, status.Error(codes.Unavailable, "busy")
+		case 2:
+			grpclib.SetTrailer(ctx, metadata.Pairs(pushbackKey, "-1"))
+			return nil, status.Error(codes.Unavailable, "go away")
+		}
+		return handler(ctx, req)
+	})
+	client := newTestClient(t, addr)
+
+	start := time.Now()
+	err := client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{},
+		network.WithRetry(5, time.Millisecond, time.Second, 2))
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected a negative pushback to stop retries, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("Expected 2 calls, got %d", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected the pushback delay to be honored, took %v", elapsed)
+	}
+}
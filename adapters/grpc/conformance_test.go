@@ -0,0 +1,20 @@
+package grpc
+
+import (
+	"testing"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/network/networktest"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	_ network.Client    = (*Client)(nil)
+	_ network.RPCClient = (*Client)(nil)
+)
+
+func TestConformance(t *testing.T) {
+	client := newTestClient(t, startServer(t, nil))
+	networktest.RPC(t, client, checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	networktest.UnsupportedHTTP(t, client)
+}
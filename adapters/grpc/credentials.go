@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/festus/microkit/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// WithTLS secures the connection with TLS using cfg, or with the system
+// CA pool if cfg is nil. Without any TLS option the connection is
+// insecure.
+func WithTLS(cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.tls = true
+		c.tlsConfig = cfg
+	}
+}
+
+// WithCAFile secures the connection with TLS, trusting the PEM-encoded CA
+// certificates in path instead of the system pool.
+func WithCAFile(path string) Option {
+	return func(c *Client) {
+		c.tls = true
+		c.caFile = path
+	}
+}
+
+// WithClientCertificate enables mutual TLS with the PEM-encoded key pair
+// in certFile and keyFile. The files are checked at most once a second
+// during handshakes and reloaded when they change, so rotated
+// certificates are picked up by new connections without a restart.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *Client) {
+		c.tls = true
+		c.certFile = certFile
+		c.keyFile = keyFile
+	}
+}
+
+// TokenFunc returns a bearer token and when it expires. A zero expiry
+// means the token is fetched again for every RPC.
+type TokenFunc func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// WithBearerToken sends "authorization: Bearer <token>" on every RPC. It
+// is WithOAuth2 over an oauth2.Source that fetches tokens with fn, so the
+// token is cached until shortly before it expires and refreshed once for
+// all concurrent RPCs. Tokens are only sent over TLS.
+func WithBearerToken(fn TokenFunc) Option {
+	return WithOAuth2(oauth2.NewSource(func(ctx context.Context) (*oauth2.Token, error) {
+		token, expiry, err := fn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetch token: %w", err)
+		}
+		if expiry.IsZero() {
+			// Expiring at once has the Source fetch it again next time.
+			expiry = time.Now()
+		}
+		return &oauth2.Token{AccessToken: token, Expiry: expiry}, nil
+	}))
+}
+
+// WithAuthority overrides the :authority header and the name the server
+// certificate is verified against, for targets reached by IP address or
+// through a proxy.
+func WithAuthority(authority string) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, grpc.WithAuthority(authority))
+	}
+}
+
+// WithBlock makes NewClient wait until the connection is ready, failing
+// if it is not within the timeout passed to NewClient.
+func WithBlock() Option {
+	return func(c *Client) {
+		c.block = true
+	}
+}
+
+// transportCredentials builds the TLS credentials configured by options,
+// or returns nil for an insecure connection.
+func (c *Client) transportCredentials() (credentials.TransportCredentials, error) {
+	if !c.tls {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.tlsConfig != nil {
+		cfg = c.tlsConfig.Clone()
+	}
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("grpc: no certificates in CA file %s", c.caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.certFile != "" {
+		r := &certReloader{certFile: c.certFile, keyFile: c.keyFile, now: time.Now}
+		if _, err := r.certificate(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate()
+		}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// certReloader serves a key pair from disk, reloading it when either file
+// changes. A pair that fails to load keeps the previous one in use.
+type certReloader struct {
+	certFile, keyFile string
+	now               func() time.Time
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if r.cert != nil && now.Sub(r.checkedAt) < time.Second {
+		return r.cert, nil
+	}
+	r.checkedAt = now
+
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err == nil && r.cert != nil && modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	if err == nil {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(r.certFile, r.keyFile); err == nil {
+			r.cert, r.modTime = &cert, modTime
+			return r.cert, nil
+		}
+	}
+	if r.cert != nil {
+		return r.cert, nil
+	}
+	return nil, fmt.Errorf("grpc: load client certificate: %w", err)
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,218 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string, serial int64) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLSWithBearerToken(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem)
+	clientCert, clientKey := ca.issue(t, "client", 2)
+	writeFile(t, filepath.Join(dir, "client.pem"), clientCert)
+	writeFile(t, filepath.Join(dir, "client.key"), clientKey)
+
+	serverCertPEM, serverKeyPEM := ca.issue(t, "orders.internal", 3)
+	serverCert, err := tls.X509KeyPair(serverCertPEM, serverKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	var authorization, clientName atomic.Value
+	srv := grpclib.NewServer(
+		grpclib.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})),
+		grpclib.UnaryInterceptor(func(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			authorization.Store(md.Get("authorization"))
+			p, _ := peer.FromContext(ctx)
+			clientName.Store(p.AuthInfo.(credentials.TLSInfo).State.PeerCertificates[0].Subject.CommonName)
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	var fetches atomic.Int32
+	client, err := NewClient(ln.Addr().String(), 5*time.Second,
+		WithCAFile(filepath.Join(dir, "ca.pem")),
+		WithClientCertificate(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")),
+		WithAuthority("orders.internal"),
+		WithBearerToken(func(context.Context) (string, time.Time, error) {
+			fetches.Add(1)
+			return "secret", time.Now().Add(time.Hour), nil
+		}),
+		WithBlock(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		err := client.Call(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+	}
+	if got := authorization.Load().([]string); len(got) != 1 || got[0] != "Bearer secret" {
+		t.Fatalf("Expected the bearer token, got %v", got)
+	}
+	if got := clientName.Load(); got != "client" {
+		t.Fatalf("Expected the client certificate, got %v", got)
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("Expected the token to be cached, fetched %d times", fetches.Load())
+	}
+}
+
+func TestCertReloaderPicksUpRotation(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	cert, key := ca.issue(t, "first", 2)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	now := time.Now()
+	r := &certReloader{certFile: certFile, keyFile: keyFile, now: func() time.Time { return now }}
+	first, err := r.certificate()
+	if err != nil {
+		t.Fatalf("certificate failed: %v", err)
+	}
+
+	cert, key = ca.issue(t, "second", 3)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+	later := now.Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	if got, _ := r.certificate(); got != first {
+		t.Fatal("Expected files not to be checked again within a second")
+	}
+	now = now.Add(2 * time.Second)
+	second, err := r.certificate()
+	if err != nil {
+		t.Fatalf("certificate failed: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(second.Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Fatalf("Expected the rotated certificate, got %s", leaf.Subject.CommonName)
+	}
+
+	// A broken file keeps the last good pair.
+	writeFile(t, keyFile, []byte("garbage"))
+	os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute))
+	now = now.Add(2 * time.Second)
+	if got, err := r.certificate(); err != nil || got != second {
+		t.Fatalf("Expected the previous certificate after a bad reload, got %v", err)
+	}
+}
+
+func TestBlockHonorsTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	start := time.Now()
+	if _, err := NewClient(addr, 200*time.Millisecond, WithBlock()); err == nil {
+		t.Fatal("Expected NewClient to fail when nothing is listening")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected NewClient to give up after the timeout, took %v", elapsed)
+	}
+}
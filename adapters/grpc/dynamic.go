@@ -0,0 +1,256 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/festus/microkit/network"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ErrStreamingMethod is returned by CallJSON for streaming methods.
+var ErrStreamingMethod = errors.New("grpc: CallJSON does not support streaming methods")
+
+// DescriptorSource finds the descriptors of methods called by name.
+type DescriptorSource interface {
+	// FindMethod returns the descriptor of method, given as
+	// "/pkg.Service/Method" or "pkg.Service/Method".
+	FindMethod(ctx context.Context, method string) (protoreflect.MethodDescriptor, error)
+}
+
+// CallJSON calls method with a request decoded from JSON and returns the
+// response encoded as JSON, using src to describe both messages. It goes
+// through Call, so every network.Option applies.
+func (c *Client) CallJSON(ctx context.Context, src DescriptorSource, method string, body []byte, opts ...network.Option) ([]byte, error) {
+	md, err := src.FindMethod(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%w: %s", ErrStreamingMethod, md.FullName())
+	}
+
+	req := dynamicpb.NewMessage(md.Input())
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, req); err != nil {
+			return nil, fmt.Errorf("grpc: decode %s: %w", md.Input().FullName(), err)
+		}
+	}
+	resp := dynamicpb.NewMessage(md.Output())
+	fullMethod := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+	if err := c.Call(ctx, fullMethod, req, resp, opts...); err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(resp)
+}
+
+// FileDescriptorSetSource describes methods from a descriptor set, such as
+// one written by protoc --descriptor_set_out --include_imports.
+func FileDescriptorSetSource(set *descriptorpb.FileDescriptorSet) (DescriptorSource, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("grpc: descriptor set: %w", err)
+	}
+	return filesSource{files}, nil
+}
+
+type filesSource struct {
+	files *protoregistry.Files
+}
+
+func (s filesSource) FindMethod(_ context.Context, method string) (protoreflect.MethodDescriptor, error) {
+	return findMethod(s.files, method)
+}
+
+// ReflectionSource describes methods by asking c's server through the
+// gRPC server reflection service (grpc.reflection.v1). Each service is
+// looked up once and cached for the life of the source.
+func ReflectionSource(c *Client) DescriptorSource {
+	return &reflectionSource{client: reflectionpb.NewServerReflectionClient(c.conn), files: new(protoregistry.Files)}
+}
+
+type reflectionSource struct {
+	client reflectionpb.ServerReflectionClient
+
+	mu    sync.Mutex
+	files *protoregistry.Files
+}
+
+func (s *reflectionSource) FindMethod(ctx context.Context, method string) (protoreflect.MethodDescriptor, error) {
+	service, _, err := splitMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.files.FindDescriptorByName(protoreflect.FullName(service)); errors.Is(err, protoregistry.NotFound) {
+		if err := s.load(ctx, service); err != nil {
+			return nil, err
+		}
+	}
+	return findMethod(s.files, method)
+}
+
+// load fetches the file defining service and every dependency not yet
+// known, and registers them. s.mu must be held.
+func (s *reflectionSource) load(ctx context.Context, service string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := s.client.ServerReflectionInfo(ctx)
+	if err != nil {
+		return newStatusError("reflection", err)
+	}
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	add := func(req *reflectionpb.ServerReflectionRequest) error {
+		if err := stream.Send(req); err != nil {
+			return newStatusError("reflection", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return newStatusError("reflection", err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return fmt.Errorf("grpc: reflection: %s", e.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, fd); err != nil {
+				return fmt.Errorf("grpc: reflection: %w", err)
+			}
+			protos[fd.GetName()] = fd
+		}
+		return nil
+	}
+
+	err = add(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return err
+	}
+	// Servers usually send dependencies along; ask for any they left out.
+	for missing := s.missing(protos); missing != ""; missing = s.missing(protos) {
+		err := add(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: missing},
+		})
+		if err != nil {
+			return err
+		}
+		if _, ok := protos[missing]; !ok {
+			return fmt.Errorf("grpc: reflection: server did not return %s", missing)
+		}
+	}
+
+	for name := range protos {
+		if err := s.register(name, protos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missing returns a dependency of protos that is neither among them nor
+// registered, or "".
+func (s *reflectionSource) missing(protos map[string]*descriptorpb.FileDescriptorProto) string {
+	for _, fd := range protos {
+		for _, dep := range fd.GetDependency() {
+			if _, ok := protos[dep]; ok {
+				continue
+			}
+			if _, err := s.resolver().FindFileByPath(dep); err != nil {
+				return dep
+			}
+		}
+	}
+	return ""
+}
+
+// register adds the file name, after its dependencies, unless it is
+// already registered. Dependencies the server left out resolve from the
+// files compiled into the binary.
+func (s *reflectionSource) register(name string, protos map[string]*descriptorpb.FileDescriptorProto) error {
+	if _, err := s.files.FindFileByPath(name); err == nil {
+		return nil
+	}
+	fd, ok := protos[name]
+	if !ok {
+		return nil
+	}
+	for _, dep := range fd.GetDependency() {
+		if err := s.register(dep, protos); err != nil {
+			return err
+		}
+	}
+	file, err := protodesc.NewFile(fd, s.resolver())
+	if err != nil {
+		return fmt.Errorf("grpc: reflection: %s: %w", name, err)
+	}
+	return s.files.RegisterFile(file)
+}
+
+func (s *reflectionSource) resolver() protodesc.Resolver {
+	return chainResolver{s.files, protoregistry.GlobalFiles}
+}
+
+// chainResolver looks descriptors up in each registry in turn.
+type chainResolver []*protoregistry.Files
+
+func (r chainResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, files := range r {
+		if fd, err := files.FindFileByPath(path); err == nil {
+			return fd, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+func (r chainResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, files := range r {
+		if d, err := files.FindDescriptorByName(name); err == nil {
+			return d, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+func findMethod(files *protoregistry.Files, method string) (protoreflect.MethodDescriptor, error) {
+	service, name, err := splitMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("grpc: unknown service %s", service)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("grpc: %s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(name))
+	if md == nil {
+		return nil, fmt.Errorf("grpc: unknown method %s/%s", service, name)
+	}
+	return md, nil
+}
+
+// splitMethod splits "/pkg.Service/Method" into its service and method.
+func splitMethod(method string) (service, name string, err error) {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok || service == "" || name == "" {
+		return "", "", fmt.Errorf("grpc: malformed method %q", method)
+	}
+	return service, name, nil
+}
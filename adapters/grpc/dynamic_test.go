@@ -0,0 +1,122 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// startReflectionServer serves the health service with server reflection
+// enabled, counting the reflection streams opened.
+func startReflectionServer(t *testing.T, lookups *atomic.Int32) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpclib.NewServer(grpclib.StreamInterceptor(func(srv any, ss grpclib.ServerStream, info *grpclib.StreamServerInfo, handler grpclib.StreamHandler) error {
+		lookups.Add(1)
+		return handler(srv, ss)
+	}))
+	hs := health.NewServer()
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String()
+}
+
+// jsonStatus decodes a health check response. protojson varies its
+// whitespace on purpose, so responses are not compared as strings.
+func jsonStatus(t *testing.T, resp []byte) string {
+	t.Helper()
+	var body struct{ Status string }
+	if err := json.Unmarshal(resp, &body); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", resp, err)
+	}
+	return body.Status
+}
+
+func TestCallJSONReflection(t *testing.T) {
+	var lookups atomic.Int32
+	client := newTestClient(t, startReflectionServer(t, &lookups))
+	src := ReflectionSource(client)
+
+	resp, err := client.CallJSON(context.Background(), src, checkMethod, []byte(`{"service":"orders"}`))
+	if err != nil {
+		t.Fatalf("CallJSON failed: %v", err)
+	}
+	if got := jsonStatus(t, resp); got != "NOT_SERVING" {
+		t.Fatalf("Unexpected response %s", resp)
+	}
+
+	// The descriptors are cached, so further calls skip reflection.
+	for _, method := range []string{checkMethod, "grpc.health.v1.Health/Check"} {
+		if _, err := client.CallJSON(context.Background(), src, method, nil); err != nil {
+			t.Fatalf("CallJSON %s failed: %v", method, err)
+		}
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Fatalf("Expected 1 reflection lookup, got %d", got)
+	}
+}
+
+func TestCallJSONErrors(t *testing.T) {
+	var lookups atomic.Int32
+	client := newTestClient(t, startReflectionServer(t, &lookups))
+	src := ReflectionSource(client)
+	ctx := context.Background()
+
+	if _, err := client.CallJSON(ctx, src, "/grpc.health.v1.Health/Watch", nil); !errors.Is(err, ErrStreamingMethod) {
+		t.Fatalf("Expected ErrStreamingMethod, got %v", err)
+	}
+	if _, err := client.CallJSON(ctx, src, "/grpc.health.v1.Health/Nope", nil); err == nil {
+		t.Fatal("Expected an error for an unknown method")
+	}
+	if _, err := client.CallJSON(ctx, src, "/acme.Missing/Get", nil); err == nil {
+		t.Fatal("Expected an error for an unknown service")
+	}
+	if _, err := client.CallJSON(ctx, src, checkMethod, []byte(`{"bogus":1}`)); err == nil {
+		t.Fatal("Expected an error for a field the request lacks")
+	}
+
+	var statusErr *StatusError
+	_, err := client.CallJSON(ctx, src, checkMethod, []byte(`{"service":"unknown"}`))
+	if !errors.As(err, &statusErr) || statusErr.Code != codes.NotFound {
+		t.Fatalf("Expected a NotFound *StatusError, got %v", err)
+	}
+}
+
+func TestCallJSONDescriptorSet(t *testing.T) {
+	var lookups atomic.Int32
+	client := newTestClient(t, startReflectionServer(t, &lookups))
+
+	src, err := FileDescriptorSetSource(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	})
+	if err != nil {
+		t.Fatalf("FileDescriptorSetSource failed: %v", err)
+	}
+	resp, err := client.CallJSON(context.Background(), src, checkMethod, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("CallJSON failed: %v", err)
+	}
+	if got := jsonStatus(t, resp); got != "SERVING" {
+		t.Fatalf("Unexpected response %s", resp)
+	}
+	if got := lookups.Load(); got != 0 {
+		t.Fatalf("Expected no reflection lookups, got %d", got)
+	}
+}
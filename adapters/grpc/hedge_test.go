@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCallHedging(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first call stalls until it is cancelled; later ones answer at once.
+	var calls atomic.Int32
+	srv := grpclib.NewServer(grpclib.UnaryInterceptor(
+		func(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+			if calls.Add(1) == 1 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	client, err := NewClient(ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	resp := &healthpb.HealthCheckResponse{}
+	err = client.Call(context.Background(), "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, resp,
+		network.WithTimeout(2*time.Second), network.WithHedging(20*time.Millisecond, 1))
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected the hedge's response, got %v", resp.GetStatus())
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("Expected 2 calls, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/festus/microkit/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WithOAuth2 sends "authorization: Bearer <token>" with tokens from ts on
+// every RPC. When a unary RPC fails with Unauthenticated and ts is an
+// oauth2.Refresher, such as an *oauth2.Source, the token is refreshed and
+// the RPC sent once more. Tokens are only sent over TLS.
+func WithOAuth2(ts oauth2.TokenSource) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts,
+			grpc.WithChainUnaryInterceptor(oauthUnary(ts)),
+			grpc.WithChainStreamInterceptor(oauthStream(ts)),
+		)
+	}
+}
+
+func oauthUnary(ts oauth2.TokenSource) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		token, err := ts.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("grpc: oauth2: %w", err)
+		}
+		err = invoker(ctx, method, req, reply, cc, append(opts, grpc.PerRPCCredentials(tokenCredentials{token}))...)
+		refresher, ok := ts.(oauth2.Refresher)
+		if status.Code(err) != codes.Unauthenticated || !ok {
+			return err
+		}
+
+		fresh, rerr := refresher.Refresh(ctx, token)
+		if rerr != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, append(opts, grpc.PerRPCCredentials(tokenCredentials{fresh}))...)
+	}
+}
+
+func oauthStream(ts oauth2.TokenSource) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		token, err := ts.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("grpc: oauth2: %w", err)
+		}
+		return streamer(ctx, desc, cc, method, append(opts, grpc.PerRPCCredentials(tokenCredentials{token}))...)
+	}
+}
+
+// tokenCredentials sends one OAuth2 token.
+type tokenCredentials struct {
+	token *oauth2.Token
+}
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token.AccessToken}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,84 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/oauth2"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestOAuth2RefreshesOnUnauthenticated(t *testing.T) {
+	var issued atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, issued.Add(1))
+	}))
+	defer tokenSrv.Close()
+
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "orders.internal", 2)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server has already revoked the first token.
+	var rejected atomic.Int32
+	srv := grpclib.NewServer(
+		grpclib.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})),
+		grpclib.UnaryInterceptor(func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer token-2" {
+				rejected.Add(1)
+				return nil, status.Error(codes.Unauthenticated, "token revoked")
+			}
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	src := oauth2.ClientCredentials(oauth2.Config{TokenURL: tokenSrv.URL, ClientID: "orders"})
+	client := newTestClient(t, ln.Addr().String(),
+		WithTLS(&tls.Config{RootCAs: pool}), WithAuthority("orders.internal"), WithOAuth2(src))
+
+	for i := 0; i < 3; i++ {
+		if err := client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+	}
+	if rejected.Load() != 1 || issued.Load() != 2 {
+		t.Fatalf("Expected one rejection and one refresh, got %d rejections and %d tokens", rejected.Load(), issued.Load())
+	}
+}
+
+func TestOAuth2RequiresTLS(t *testing.T) {
+	src := oauth2.NewSource(func(context.Context) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "secret", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	client := newTestClient(t, startServer(t, nil), WithOAuth2(src))
+
+	err := client.Call(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	if err == nil {
+		t.Fatal("Expected the token to be withheld from an insecure connection")
+	}
+}
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/ratelimit"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCallRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	client, err := NewClient(startHealthServer(t, &calls), time.Second)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	limiter := ratelimit.New(0.1, 1, ratelimit.FailFast)
+	concurrency := ratelimit.NewAdaptive(ratelimit.AdaptiveConfig{})
+	call := func() error {
+		return client.Call(context.Background(), "/grpc.health.v1.Health/Check",
+			&healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{},
+			network.WithRateLimit(limiter), network.WithConcurrencyLimit(concurrency),
+			network.WithRetry(3, time.Millisecond, time.Millisecond, 2))
+	}
+
+	if err := call(); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if err := call(); !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected 1 call to reach the server, got %d", calls.Load())
+	}
+	if concurrency.InFlight() != 0 {
+		t.Fatalf("Expected every permit to be returned, got %d in flight", concurrency.InFlight())
+	}
+}
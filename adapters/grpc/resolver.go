@@ -0,0 +1,142 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/festus/microkit/discovery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+// DiscoveryScheme is the target scheme served by WithDiscovery, as in
+// "discovery:///orders".
+const DiscoveryScheme = "discovery"
+
+// WithDiscovery resolves "discovery:///<service>" targets through dir,
+// following dir as the endpoints change, and picks the endpoint of each
+// call with dir's Balancer. Calls failing with the codes that count
+// against circuit breakers, such as Unavailable or Internal, count
+// towards dir's outlier ejection.
+func WithDiscovery(dir *discovery.Directory) Option {
+	return WithDialOptions(
+		grpc.WithResolvers(discoveryBuilder{dir: dir}),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig":[{"`+discoveryBalancer+`":{}}]}`),
+	)
+}
+
+type discoveryBuilder struct {
+	dir *discovery.Directory
+}
+
+func (b discoveryBuilder) Scheme() string {
+	return DiscoveryScheme
+}
+
+func (b discoveryBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &discoveryResolver{cancel: cancel}
+
+	// The balancer finds the directory through the addresses.
+	attrs := attributes.New(discoveryTargetKey{}, discoveryTarget{dir: b.dir, service: target.Endpoint()})
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		b.dir.Watch(ctx, target.Endpoint(), func(eps []discovery.Endpoint, err error) {
+			if err != nil {
+				cc.ReportError(err)
+				return
+			}
+			state := resolver.State{
+				Addresses: make([]resolver.Address, len(eps)),
+				Endpoints: make([]resolver.Endpoint, len(eps)),
+			}
+			for i, ep := range eps {
+				addr := resolver.Address{Addr: ep.Addr, BalancerAttributes: attrs}
+				state.Addresses[i] = addr
+				state.Endpoints[i] = resolver.Endpoint{Addresses: []resolver.Address{addr}}
+			}
+			cc.UpdateState(state)
+		})
+	}()
+	return r, nil
+}
+
+type discoveryResolver struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// ResolveNow is a no-op: the directory is polled at its refresh interval.
+func (r *discoveryResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *discoveryResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// discoveryBalancer is the load balancing policy WithDiscovery selects.
+const discoveryBalancer = "microkit_discovery"
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(discoveryBalancer, discoveryPickerBuilder{}, base.Config{}))
+}
+
+type discoveryTargetKey struct{}
+
+// discoveryTarget is the directory and service a resolved address came
+// from.
+type discoveryTarget struct {
+	dir     *discovery.Directory
+	service string
+}
+
+type discoveryPickerBuilder struct{}
+
+func (discoveryPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &discoveryPicker{ready: make(map[string]balancer.SubConn, len(info.ReadySCs))}
+	for sc, sci := range info.ReadySCs {
+		p.ready[sci.Address.Addr] = sc
+		p.all = append(p.all, sc)
+		if t, ok := sci.Address.BalancerAttributes.Value(discoveryTargetKey{}).(discoveryTarget); ok {
+			p.target = t
+		}
+	}
+	return p
+}
+
+// discoveryPicker asks the directory for an endpoint and sends the call
+// on its connection, reporting the outcome back for outlier ejection.
+type discoveryPicker struct {
+	target discoveryTarget
+	ready  map[string]balancer.SubConn
+	all    []balancer.SubConn
+	next   atomic.Uint32
+}
+
+func (p *discoveryPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	// Pick must not block, so it picks among the endpoints the resolver
+	// already fetched, never resolving itself.
+	if p.target.dir != nil {
+		ep, done, err := p.target.dir.PickCached(info.Ctx, p.target.service)
+		if err == nil {
+			if sc, ok := p.ready[ep.Addr]; ok {
+				return balancer.PickResult{SubConn: sc, Done: func(di balancer.DoneInfo) {
+					done(endpointFailure(di.Err))
+				}}, nil
+			}
+			// Not connected: the call never reaches the endpoint.
+			done.Release()
+		}
+	}
+	// Round robin over the ready connections instead.
+	return balancer.PickResult{SubConn: p.all[int(p.next.Add(1))%len(p.all)]}, nil
+}
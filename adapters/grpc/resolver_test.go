@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/discovery"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// startHealthServer serves the gRPC health service and counts the calls
+// it receives.
+func startHealthServer(t *testing.T, calls *atomic.Int32) string {
+	return startServer(t, func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		calls.Add(1)
+		return handler(ctx, req)
+	})
+}
+
+func TestDiscoveryResolver(t *testing.T) {
+	var a, b atomic.Int32
+	dir := discovery.New(discovery.Static(map[string][]string{
+		"health": {startHealthServer(t, &a), startHealthServer(t, &b)},
+	}))
+
+	client, err := NewClient("discovery:///health", time.Second, WithDiscovery(dir))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 20; i++ {
+		resp := &healthpb.HealthCheckResponse{}
+		if err := client.Call(ctx, "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, resp); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+	}
+	if a.Load() == 0 || b.Load() == 0 {
+		t.Fatalf("Expected calls on both endpoints, got %d and %d", a.Load(), b.Load())
+	}
+}
+
+func TestDiscoveryEjectsFailingEndpoint(t *testing.T) {
+	var good, bad atomic.Int32
+	failing := startServer(t, func(context.Context, any, *grpclib.UnaryServerInfo, grpclib.UnaryHandler) (any, error) {
+		bad.Add(1)
+		return nil, status.Error(codes.Unavailable, "overloaded")
+	})
+	dir := discovery.New(discovery.Static(map[string][]string{
+		"health": {startHealthServer(t, &good), failing},
+	}), discovery.WithOutlierEjection(2, time.Minute))
+
+	client, err := NewClient("discovery:///health", time.Second, WithDiscovery(dir), WithBlock())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	call := func(n int) {
+		for i := 0; i < n; i++ {
+			client.Call(ctx, "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+		}
+	}
+	call(20)
+	ejected := bad.Load()
+	if ejected < 2 {
+		t.Fatalf("Expected the failing endpoint to be tried, got %d calls", ejected)
+	}
+	call(20)
+	if bad.Load() != ejected {
+		t.Fatalf("Expected no calls on the ejected endpoint, got %d more", bad.Load()-ejected)
+	}
+}
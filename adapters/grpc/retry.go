@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/retry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryableCodes are the status codes retried unless
+// WithRetryableCodes says otherwise.
+var DefaultRetryableCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded}
+
+// WithRetryableCodes sets the status codes on which calls made with a
+// network retry option are retried. Defaults to DefaultRetryableCodes.
+func WithRetryableCodes(retryable ...codes.Code) Option {
+	return func(c *Client) {
+		c.retryCodes = retryable
+	}
+}
+
+// pushbackKey is the trailer in which servers ask clients to delay, or
+// with a negative or malformed value to stop, retries.
+const pushbackKey = "grpc-retry-pushback-ms"
+
+// retryable prepares err for retry.Do: errors with a status code outside
+// the retryable set, and local rejections by a breaker, limiter or
+// bulkhead, are permanent, and server pushback in trailer sets the delay.
+func (c *Client) retryable(err error, trailer metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+	if rejected(err) || !slices.Contains(c.retryCodes, status.Code(err)) {
+		return retry.Permanent(err)
+	}
+
+	if v := trailer.Get(pushbackKey); len(v) > 0 {
+		ms, perr := strconv.Atoi(v[0])
+		if perr != nil || ms < 0 {
+			return retry.Permanent(err)
+		}
+		return retry.RetryAfter(err, time.Duration(ms)*time.Millisecond)
+	}
+	return err
+}
+
+// headerFromMD converts metadata to a network.Header with canonical keys.
+func headerFromMD(md metadata.MD) network.Header {
+	h := make(network.Header, len(md))
+	for k, vs := range md {
+		for _, v := range vs {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusError is returned by Call when the server, or gRPC itself, fails
+// an RPC. It keeps the full status, so status.Code and status.FromError
+// keep working on it.
+type StatusError struct {
+	Method  string
+	Code    codes.Code
+	Message string
+
+	// Details holds the status details, such as *errdetails.BadRequest,
+	// decoded as far as their types are registered.
+	Details []any
+
+	status *status.Status
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("grpc: %s: %s: %s", e.Method, e.Code, e.Message)
+}
+
+// GRPCStatus returns the status the error was built from.
+func (e *StatusError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// newStatusError wraps err in a *StatusError if it carries a gRPC status,
+// and returns it unchanged otherwise.
+func newStatusError(method string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return &StatusError{
+		Method:  method,
+		Code:    st.Code(),
+		Message: st.Message(),
+		Details: st.Details(),
+		status:  st,
+	}
+}
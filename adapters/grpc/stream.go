@@ -0,0 +1,260 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/retry"
+	"github.com/festus/microkit/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewStream opens a streaming RPC. network.WithTimeout bounds the whole
+// stream, retry options apply to opening it, and headers are sent as
+// metadata; circuit breakers, limiters and hedging apply to Call only.
+// Errors from RecvMsg are *StatusErrors. The stream is finished once
+// RecvMsg returns an error, including io.EOF; callers that stop earlier
+// must cancel ctx.
+func (c *Client) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...network.Option) (grpc.ClientStream, error) {
+	config := &network.Config{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	var cancel context.CancelFunc
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	start := time.Now()
+	ctx, span := c.tracer.StartRPC(ctx, method)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for k, v := range config.Headers {
+		md.Set(k, v...)
+	}
+	c.tracer.Inject(ctx, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	var cs grpc.ClientStream
+	open := func() error {
+		var err error
+		cs, err = c.conn.NewStream(ctx, desc, method)
+		return err
+	}
+	var err error
+	if config.Retry != nil {
+		// The stream is opened with ctx rather than the attempt's context,
+		// as it must outlive any per-attempt timeout.
+		err = retry.Do(ctx, config.Retry, func(context.Context) error {
+			return c.retryable(open(), nil)
+		}, config.RetryOptions...)
+	} else {
+		err = open()
+	}
+
+	s := &clientStream{client: c, method: method, desc: desc, config: config, span: span, start: start, cancel: cancel}
+	if err != nil {
+		s.finish(err)
+		return nil, newStatusError(method, err)
+	}
+	s.ClientStream = cs
+	// A stream abandoned by cancelling ctx still ends its span.
+	context.AfterFunc(ctx, func() {
+		s.finish(status.FromContextError(ctx.Err()).Err())
+	})
+	return s, nil
+}
+
+// clientStream ends the span, records metrics and releases the context
+// once the stream finishes.
+type clientStream struct {
+	grpc.ClientStream
+	client *Client
+	method string
+	desc   *grpc.StreamDesc
+	config *network.Config
+	span   trace.Span
+	start  time.Time
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		// Without server streaming the single response ends the stream.
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+		return nil
+	case errors.Is(err, io.EOF):
+		s.finish(nil)
+		return err
+	}
+	s.finish(err)
+	return newStatusError(s.method, err)
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		if s.ClientStream != nil {
+			if s.config.ResponseHeaders != nil {
+				md, _ := s.ClientStream.Header()
+				*s.config.ResponseHeaders = headerFromMD(md)
+			}
+			if s.config.ResponseTrailers != nil {
+				*s.config.ResponseTrailers = headerFromMD(s.ClientStream.Trailer())
+			}
+		}
+
+		code := status.Code(err)
+		s.span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+		tracing.End(s.span, err)
+		s.client.metrics.ObserveRequest("grpc", s.method, code.String(), time.Since(s.start))
+		if err != nil {
+			s.client.logger.Warn("grpc: stream failed", "method", s.method, "code", code.String(), "error", err)
+		}
+		s.cancel()
+	})
+}
+
+// Stream is a typed view of a streaming RPC sending Req and receiving
+// Resp messages.
+type Stream[Req, Resp any] struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// ServerStream calls a server-streaming method with req, leaving only the
+// responses to read.
+func ServerStream[Req, Resp any](ctx context.Context, c *Client, method string, req *Req, opts ...network.Option) (*Stream[Req, Resp], error) {
+	s, err := openStream[Req, Resp](ctx, c, &grpc.StreamDesc{ServerStreams: true}, method, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Send(req); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.CloseSend(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// ClientStream opens a client-streaming method. Send the requests, then
+// call CloseAndRecv for the response.
+func ClientStream[Req, Resp any](ctx context.Context, c *Client, method string, opts ...network.Option) (*Stream[Req, Resp], error) {
+	return openStream[Req, Resp](ctx, c, &grpc.StreamDesc{ClientStreams: true}, method, opts)
+}
+
+// BidiStream opens a bidirectional streaming method.
+func BidiStream[Req, Resp any](ctx context.Context, c *Client, method string, opts ...network.Option) (*Stream[Req, Resp], error) {
+	return openStream[Req, Resp](ctx, c, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, method, opts)
+}
+
+func openStream[Req, Resp any](ctx context.Context, c *Client, desc *grpc.StreamDesc, method string, opts []network.Option) (*Stream[Req, Resp], error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cs, err := c.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Stream[Req, Resp]{ClientStream: cs, cancel: cancel}, nil
+}
+
+// Send sends one request.
+func (s *Stream[Req, Resp]) Send(req *Req) error {
+	return s.SendMsg(req)
+}
+
+// Recv receives one response, returning io.EOF once the server has sent
+// them all.
+func (s *Stream[Req, Resp]) Recv() (*Resp, error) {
+	resp := new(Resp)
+	if err := s.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CloseAndRecv closes the sending side and receives the single response
+// of a client-streaming method.
+func (s *Stream[Req, Resp]) CloseAndRecv() (*Resp, error) {
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+	return s.Recv()
+}
+
+// Close abandons the stream, cancelling it if it is still running.
+func (s *Stream[Req, Resp]) Close() {
+	s.cancel()
+}
+
+// All iterates over the remaining responses. It stops after the last one,
+// or after yielding the error that ended the stream. Breaking out of the
+// loop early closes the stream.
+func (s *Stream[Req, Resp]) All() iter.Seq2[*Resp, error] {
+	return func(yield func(*Resp, error) bool) {
+		defer s.Close()
+		for {
+			resp, err := s.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(resp, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Chan delivers the remaining responses on a channel until the stream
+// ends or ctx does, both of which close the stream. The error channel then
+// receives the error that ended the stream, nil at its normal end.
+func (s *Stream[Req, Resp]) Chan(ctx context.Context) (<-chan *Resp, <-chan error) {
+	out := make(chan *Resp)
+	errs := make(chan error, 1)
+	stop := context.AfterFunc(ctx, s.Close)
+
+	go func() {
+		defer close(out)
+		defer stop()
+		defer s.Close()
+		for {
+			resp, err := s.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				errs <- err
+				return
+			}
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return out, errs
+}
@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	request  = healthpb.HealthCheckRequest
+	response = healthpb.HealthCheckResponse
+)
+
+// startStreamServer serves test.Streams, whose methods reuse the health
+// messages:
+//   - List sends three responses, forever ones for "forever", or fails
+//     with NotFound for "missing".
+//   - Count returns the number of requests it received as the status.
+//   - Echo answers every request with SERVING.
+func startStreamServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpclib.NewServer()
+	srv.RegisterService(&grpclib.ServiceDesc{
+		ServiceName: "test.Streams",
+		HandlerType: (*any)(nil),
+		Streams: []grpclib.StreamDesc{
+			{StreamName: "List", ServerStreams: true, Handler: func(_ any, stream grpclib.ServerStream) error {
+				req := &request{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				md, _ := metadata.FromIncomingContext(stream.Context())
+				stream.SetHeader(metadata.Pairs("x-tenant-echo", md.Get("x-tenant")[0]))
+				stream.SetTrailer(metadata.Pairs("x-count", "3"))
+				switch req.Service {
+				case "missing":
+					return status.Error(codes.NotFound, "no such list")
+				case "forever":
+					for {
+						if err := stream.SendMsg(&response{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+							return err
+						}
+						time.Sleep(time.Millisecond)
+					}
+				}
+				for i := 0; i < 3; i++ {
+					if err := stream.SendMsg(&response{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}},
+			{StreamName: "Count", ClientStreams: true, Handler: func(_ any, stream grpclib.ServerStream) error {
+				n := 0
+				for {
+					if err := stream.RecvMsg(&request{}); errors.Is(err, io.EOF) {
+						break
+					} else if err != nil {
+						return err
+					}
+					n++
+				}
+				return stream.SendMsg(&response{Status: healthpb.HealthCheckResponse_ServingStatus(n)})
+			}},
+			{StreamName: "Echo", ClientStreams: true, ServerStreams: true, Handler: func(_ any, stream grpclib.ServerStream) error {
+				for {
+					if err := stream.RecvMsg(&request{}); errors.Is(err, io.EOF) {
+						return nil
+					} else if err != nil {
+						return err
+					}
+					if err := stream.SendMsg(&response{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+						return err
+					}
+				}
+			}},
+		},
+	}, nil)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String()
+}
+
+func TestServerStreamAll(t *testing.T) {
+	client := newTestClient(t, startStreamServer(t))
+
+	var headers, trailers network.Header
+	stream, err := ServerStream[request, response](context.Background(), client, "/test.Streams/List", &request{},
+		network.WithHeader("X-Tenant", "acme"), network.WithResponseMetadata(&headers, &trailers), network.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("ServerStream failed: %v", err)
+	}
+
+	n := 0
+	for resp, err := range stream.All() {
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("Unexpected response %v", resp)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("Expected 3 responses, got %d", n)
+	}
+	if headers.Get("X-Tenant-Echo") != "acme" || trailers.Get("X-Count") != "3" {
+		t.Fatalf("Expected response metadata, got %v and %v", headers, trailers)
+	}
+}
+
+func TestServerStreamError(t *testing.T) {
+	client := newTestClient(t, startStreamServer(t))
+
+	stream, err := ServerStream[request, response](context.Background(), client, "/test.Streams/List", &request{Service: "missing"},
+		network.WithHeader("X-Tenant", "acme"))
+	if err != nil {
+		t.Fatalf("ServerStream failed: %v", err)
+	}
+	var got error
+	for _, err := range stream.All() {
+		got = err
+	}
+	var statusErr *StatusError
+	if !errors.As(got, &statusErr) || statusErr.Code != codes.NotFound {
+		t.Fatalf("Expected a NotFound *StatusError, got %v", got)
+	}
+}
+
+func TestStreamChanCancel(t *testing.T) {
+	client := newTestClient(t, startStreamServer(t))
+
+	stream, err := ServerStream[request, response](context.Background(), client, "/test.Streams/List", &request{Service: "forever"},
+		network.WithHeader("X-Tenant", "acme"))
+	if err != nil {
+		t.Fatalf("ServerStream failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, errs := stream.Chan(ctx)
+	<-out
+	<-out
+	cancel()
+
+	for range out {
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientAndBidiStreams(t *testing.T) {
+	client := newTestClient(t, startStreamServer(t))
+	ctx := context.Background()
+
+	count, err := ClientStream[request, response](ctx, client, "/test.Streams/Count")
+	if err != nil {
+		t.Fatalf("ClientStream failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := count.Send(&request{}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	resp, err := count.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv failed: %v", err)
+	}
+	if resp.Status != 3 {
+		t.Fatalf("Expected the server to count 3 requests, got %v", resp.Status)
+	}
+
+	echo, err := BidiStream[request, response](ctx, client, "/test.Streams/Echo")
+	if err != nil {
+		t.Fatalf("BidiStream failed: %v", err)
+	}
+	defer echo.Close()
+	for i := 0; i < 2; i++ {
+		if err := echo.Send(&request{}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if resp, err := echo.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("Expected an echo, got %v, %v", resp, err)
+		}
+	}
+	echo.CloseSend()
+	if _, err := echo.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected io.EOF after closing, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/bulkhead"
+	"github.com/festus/microkit/network"
+)
+
+func TestBulkheadAppliesToRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second)
+	b := bulkhead.New("orders", bulkhead.Config{MaxConcurrent: 1})
+	opts := []network.Option{network.WithBulkhead(b), network.WithRetry(3, time.Millisecond, time.Millisecond, 2)}
+
+	// A streamed response holds its slot until the body is closed.
+	held, err := client.Stream(context.Background(), StreamRequest{Method: http.MethodGet, URL: server.URL}, opts...)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	_, err = client.Get(context.Background(), server.URL, opts...)
+	var rejected *bulkhead.RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != bulkhead.QueueFull {
+		t.Fatalf("Expected a bulkhead rejection, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected the rejected request not to be retried past the bulkhead, got %d calls", calls.Load())
+	}
+
+	held.Body.Close()
+	if _, err := client.Get(context.Background(), server.URL, opts...); err != nil {
+		t.Fatalf("Expected the slot to be free after closing the body, got %v", err)
+	}
+	if b.InFlight() != 0 {
+		t.Fatalf("Expected no slots held, got %d", b.InFlight())
+	}
+}
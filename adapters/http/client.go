@@ -3,121 +3,249 @@ package http
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/festus/microkit/internal/logging"
+	"github.com/festus/microkit/metrics"
 	"github.com/festus/microkit/network"
-	"github.com/festus/microkit/internal/retry"
+	"github.com/festus/microkit/tracing"
 )
 
 type Client struct {
-	client *http.Client
+	client     *http.Client
+	transport  http.RoundTripper
+	middleware []Middleware
+	timeout    time.Duration
+	tracer     *tracing.Tracer
+	metrics    metrics.Recorder
+	logger     *slog.Logger
+
+	healthURL string
 }
 
-func NewClient(timeout time.Duration) *Client {
-	return &Client{
-		client: &http.Client{
-			Timeout: timeout,
-		},
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTracer sets the tracer used to create client spans and propagate
+// trace context in request headers.
+func WithTracer(t *tracing.Tracer) Option {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// WithMetrics sets the recorder for request duration and status measurements.
+func WithMetrics(r metrics.Recorder) Option {
+	return func(c *Client) {
+		c.metrics = r
+	}
+}
+
+// WithLogger sets the logger for failed requests. Defaults to a logger
+// that discards everything.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithTransport sets the RoundTripper that sends requests, below all
+// middleware. Defaults to http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// WithMiddleware appends mws to the client's middleware chain. They run
+// inside the default Retry and CircuitBreaker middlewares, once per
+// attempt.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mws...)
+	}
+}
+
+// WithMiddlewareChain replaces the whole middleware chain, including the
+// defaults, with mws. Include Retry and CircuitBreaker, or replacements
+// for them, wherever they should run.
+func WithMiddlewareChain(mws ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = mws
+	}
+}
+
+// WithHealthCheckURL makes Check issue a GET to url and treat any status
+// below 400 as healthy. Without it Check always succeeds.
+func WithHealthCheckURL(url string) Option {
+	return func(c *Client) {
+		c.healthURL = url
 	}
 }
 
+func NewClient(timeout time.Duration, opts ...Option) *Client {
+	c := &Client{
+		transport:  http.DefaultTransport,
+		middleware: DefaultMiddleware(),
+		timeout:    timeout,
+		tracer:     tracing.New(),
+		metrics:    metrics.Nop{},
+		logger:     logging.Discard(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.client = &http.Client{
+		Transport: Chain(c.instrument(c.transport), c.middleware...),
+	}
+	return c
+}
+
 func (c *Client) Get(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
-	return c.do(ctx, "GET", url, nil, opts...)
+	return c.Do(ctx, network.Request{Method: "GET", URL: url}, opts...)
+}
+
+func (c *Client) Head(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
+	return c.Do(ctx, network.Request{Method: "HEAD", URL: url}, opts...)
+}
+
+func (c *Client) Options(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
+	return c.Do(ctx, network.Request{Method: "OPTIONS", URL: url}, opts...)
 }
 
 func (c *Client) Post(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
-	return c.do(ctx, "POST", url, body, opts...)
+	return c.Do(ctx, network.Request{Method: "POST", URL: url, Body: body}, opts...)
 }
 
 func (c *Client) Put(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
-	return c.do(ctx, "PUT", url, body, opts...)
+	return c.Do(ctx, network.Request{Method: "PUT", URL: url, Body: body}, opts...)
 }
 
 func (c *Client) Patch(ctx context.Context, url string, body []byte, opts ...network.Option) (*network.Response, error) {
-	return c.do(ctx, "PATCH", url, body, opts...)
+	return c.Do(ctx, network.Request{Method: "PATCH", URL: url, Body: body}, opts...)
 }
 
 func (c *Client) Delete(ctx context.Context, url string, opts ...network.Option) (*network.Response, error) {
-	return c.do(ctx, "DELETE", url, nil, opts...)
+	return c.Do(ctx, network.Request{Method: "DELETE", URL: url}, opts...)
 }
 
 func (c *Client) Call(ctx context.Context, method string, req interface{}, resp interface{}, opts ...network.Option) error {
 	return network.ErrUnsupportedOperation
 }
 
-func (c *Client) do(ctx context.Context, method, url string, body []byte, opts ...network.Option) (*network.Response, error) {
+// Do sends req with any method. Headers set through opts replace those of
+// the same name in req.Headers.
+func (c *Client) Do(ctx context.Context, req network.Request, opts ...network.Option) (*network.Response, error) {
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	}
+
+	httpReq, call, err := newRequest(ctx, req.Method, req.URL, body, req.Headers, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := newResponse(resp, start)
+	out.Body = respBody
+	storeMetadata(call.config, resp)
+	return out, call.result(out)
+}
+
+// newRequest builds a request with headers, overridden by the headers of
+// the call configured by opts.
+func newRequest(ctx context.Context, method, url string, body io.Reader, headers network.Header, opts []network.Option) (*http.Request, *call, error) {
 	config := &network.Config{}
 	for _, opt := range opts {
 		opt(config)
 	}
+	call := &call{config: config}
 
-	if config.RetryConfig != nil {
-		var resp *network.Response
-		err := retry.Execute(ctx, *config.RetryConfig, func() error {
-			var err error
-			resp, err = c.doRequest(ctx, method, url, body, config)
-			if err != nil {
-				return err
-			}
-			// Retry on specific status codes if configured
-			if len(config.RetryStatusCodes) > 0 {
-				for _, code := range config.RetryStatusCodes {
-					if resp.StatusCode == code {
-						return retry.ErrRetryableStatus
-					}
-				}
+	req, err := http.NewRequestWithContext(withCall(ctx, call), method, url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, h := range []network.Header{headers, config.Headers} {
+		for k, vs := range h {
+			req.Header.Del(k)
+			for _, v := range vs {
+				req.Header.Add(k, v)
 			}
-			return nil
-		})
-		return resp, err
+		}
 	}
+	return req, call, nil
+}
 
-	return c.doRequest(ctx, method, url, body, config)
+// storeMetadata fills the network.WithResponseMetadata targets. Trailers
+// are filled in by net/http as the body is read to the end.
+func storeMetadata(config *network.Config, resp *http.Response) {
+	if config.ResponseHeaders != nil {
+		*config.ResponseHeaders = network.Header(resp.Header)
+	}
+	if config.ResponseTrailers != nil {
+		*config.ResponseTrailers = network.Header(resp.Trailer)
+	}
 }
 
-func (c *Client) doRequest(ctx context.Context, method, url string, body []byte, config *network.Config) (*network.Response, error) {
-	var bodyReader io.Reader
-	if body != nil {
-		bodyReader = bytes.NewReader(body)
+// newResponse describes resp, without its body.
+func newResponse(resp *http.Response, start time.Time) *network.Response {
+	out := &network.Response{
+		StatusCode:    resp.StatusCode,
+		Headers:       network.Header(resp.Header),
+		Proto:         resp.Proto,
+		ContentLength: resp.ContentLength,
+		Elapsed:       time.Since(start),
+	}
+	if resp.Request != nil {
+		out.URL = resp.Request.URL.String()
 	}
+	return out
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
-	if err != nil {
-		return nil, err
+func (c *Client) Close() error {
+	if t, ok := c.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
 	}
+	return nil
+}
 
-	for k, v := range config.Headers {
-		req.Header.Set(k, v)
+// Check pings the configured health check URL, if any.
+func (c *Client) Check(ctx context.Context) error {
+	if c.healthURL == "" {
+		return nil
 	}
 
-	resp, err := c.client.Do(req)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.healthURL, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
 
-	headers := make(map[string]string)
-	for k, v := range resp.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
-		}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("http: health check %s returned %d", req.URL.Redacted(), resp.StatusCode)
 	}
-
-	return &network.Response{
-		StatusCode: resp.StatusCode,
-		Headers:    headers,
-		Body:       respBody,
-	}, nil
-}
-
-func (c *Client) Close() error {
-	c.client.CloseIdleConnections()
 	return nil
 }
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+)
+
+func TestDoHeadersAndResponseMetadata(t *testing.T) {
+	var got http.Header
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Write([]byte("hello"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	resp, err := client.Do(context.Background(), network.Request{
+		Method:  "GET",
+		URL:     srv.URL + "/old",
+		Headers: network.Header{"X-Tenant": {"acme"}, "Accept": {"text/plain"}},
+	},
+		network.WithHeader("Accept", "application/json"),
+		network.WithHeaderValues("Accept-Language", "en", "de"),
+	)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+
+	if got.Get("X-Tenant") != "acme" || got.Get("Accept") != "application/json" {
+		t.Fatalf("Expected request headers with option override, got %v", got)
+	}
+	if !slices.Equal(got.Values("Accept-Language"), []string{"en", "de"}) {
+		t.Fatalf("Expected repeated Accept-Language, got %v", got.Values("Accept-Language"))
+	}
+
+	if !slices.Equal(resp.Headers.Values("Set-Cookie"), []string{"a=1", "b=2"}) {
+		t.Fatalf("Expected both cookies, got %v", resp.Headers.Values("Set-Cookie"))
+	}
+	if resp.URL != srv.URL+"/new" {
+		t.Fatalf("Expected final URL %s/new, got %s", srv.URL, resp.URL)
+	}
+	if resp.Proto != "HTTP/1.1" || resp.ContentLength != 5 || resp.Elapsed <= 0 {
+		t.Fatalf("Unexpected metadata: proto %s, length %d, elapsed %v", resp.Proto, resp.ContentLength, resp.Elapsed)
+	}
+}
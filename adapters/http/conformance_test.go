@@ -0,0 +1,20 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/network/networktest"
+)
+
+var (
+	_ network.Client     = (*Client)(nil)
+	_ network.HTTPClient = (*Client)(nil)
+)
+
+func TestConformance(t *testing.T) {
+	client := NewClient(time.Second)
+	networktest.HTTP(t, client)
+	networktest.UnsupportedRPC(t, client)
+}
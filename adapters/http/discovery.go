@@ -0,0 +1,40 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/festus/microkit/discovery"
+)
+
+// Discovery sends requests for logical URLs such as http://orders/v1/...
+// to an endpoint of the service picked by dir. Hosts the resolver does not
+// know pass through unchanged. Added with WithMiddleware it runs once per
+// attempt, so retries may reach another endpoint; transport errors and
+// 5xx responses count towards the endpoint's ejection. Requests keep the
+// logical host in their Host header, so request signatures made before or
+// after Discovery verify at the endpoint.
+func Discovery(dir *discovery.Directory) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ep, done, err := dir.Pick(req.Context(), req.URL.Host)
+			if errors.Is(err, discovery.ErrUnknownService) {
+				return next.RoundTrip(req)
+			}
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+
+			r := req.Clone(req.Context())
+			if r.Host == "" {
+				r.Host = req.URL.Host
+			}
+			r.URL.Host = ep.Addr
+
+			resp, err := next.RoundTrip(r)
+			done(outcome(resp, err))
+			return resp, err
+		})
+	}
+}
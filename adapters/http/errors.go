@@ -0,0 +1,97 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/festus/microkit/network"
+)
+
+// HTTPError describes a response with a non-2xx status. It is returned by
+// the JSON helpers and, with network.WithStatusErrors, by the plain
+// request methods.
+type HTTPError struct {
+	StatusCode int
+	Headers    network.Header
+	Body       []byte
+
+	// Problem is the decoded body of an RFC 7807 application/problem+json
+	// response, and nil otherwise.
+	Problem *Problem
+}
+
+// Problem is an RFC 7807 problem details object. Members other than the
+// standard ones are kept in Extensions.
+type Problem struct {
+	Type       string         `json:"type,omitempty"`
+	Title      string         `json:"title,omitempty"`
+	Status     int            `json:"status,omitempty"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Extensions map[string]any `json:"-"`
+}
+
+func newHTTPError(resp *network.Response) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Headers,
+		Body:       resp.Body,
+	}
+	if mediaType(resp.Headers.Get("Content-Type")) == "application/problem+json" {
+		var p Problem
+		if p.UnmarshalJSON(resp.Body) == nil {
+			e.Problem = &p
+		}
+	}
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("http: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	switch {
+	case e.Problem != nil && e.Problem.Detail != "":
+		return msg + ": " + e.Problem.Detail
+	case e.Problem != nil && e.Problem.Title != "":
+		return msg + ": " + e.Problem.Title
+	case len(e.Body) > 0 && len(e.Body) <= 200 && !strings.ContainsAny(string(e.Body), "\r\n"):
+		return msg + ": " + string(e.Body)
+	}
+	return msg
+}
+
+// Decode unmarshals the JSON error body into v, for APIs with their own
+// error format.
+func (e *HTTPError) Decode(v any) error {
+	return json.Unmarshal(e.Body, v)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	type standard Problem
+	if err := json.Unmarshal(data, (*standard)(p)); err != nil {
+		return err
+	}
+
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(all, k)
+	}
+	if len(all) > 0 {
+		p.Extensions = all
+	}
+	return nil
+}
+
+// mediaType returns the lower-cased media type of a Content-Type value.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/festus/microkit/hedge"
+)
+
+// Hedge sends extra copies of slow requests as set with network.WithHedging
+// or WithHedger, returning the first response and cancelling the rest.
+// Only idempotent requests with a replayable body are hedged. Any response
+// wins, whatever its status; Retry, placed before Hedge, decides whether
+// to try again. With Discovery placed after it, each copy picks its own
+// endpoint.
+func Hedge() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			c := callFrom(req.Context())
+			if c == nil || c.config.Hedger == nil || !idempotent(req) || !replayable(req) {
+				return next.RoundTrip(req)
+			}
+
+			return hedge.Do(req.Context(), c.config.Hedger, func(ctx context.Context, attempt int) (*http.Response, error) {
+				r, release, err := attemptRequest(ctx, req, attempt+1)
+				if err != nil {
+					return nil, err
+				}
+				res, err := next.RoundTrip(r)
+				cancel := release()
+				if err != nil {
+					cancel()
+					return nil, err
+				}
+				res.Body = &releaseBody{ReadCloser: res.Body, release: cancel}
+				return res, nil
+			}, discard)
+		})
+	}
+}
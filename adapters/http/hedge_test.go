@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/discovery"
+	"github.com/festus/microkit/network"
+)
+
+func TestHedgeTakesFastestEndpoint(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	addr := func(s *httptest.Server) string { return strings.TrimPrefix(s.URL, "http://") }
+	dir := discovery.New(discovery.Static(map[string][]string{
+		"orders": {addr(slow), addr(fast)},
+	}))
+	client := NewClient(time.Second, WithMiddleware(Discovery(dir)))
+
+	start := time.Now()
+	resp, err := client.Get(context.Background(), "http://orders/", network.WithHedging(20*time.Millisecond, 1))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(resp.Body) != "fast" {
+		t.Fatalf("Expected the hedged request to win, got %q", resp.Body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the hedge to answer quickly, took %v", elapsed)
+	}
+}
+
+func TestHedgeSkipsNonIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second)
+	if _, err := client.Post(context.Background(), server.URL, []byte("x"), network.WithHedging(5*time.Millisecond, 2)); err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected POST to be sent once, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/festus/microkit/network"
+)
+
+const acceptJSON = "application/json, application/problem+json"
+
+// DoJSON sends in, unless nil, as a JSON request body and decodes a 2xx
+// response body into out, unless nil or the body is empty. Non-2xx
+// responses are returned without decoding; add network.WithStatusErrors
+// to get them as *HTTPError.
+func DoJSON(ctx context.Context, c *Client, method, url string, in, out any, opts ...network.Option) (*network.Response, error) {
+	headers := []network.Option{network.WithHeader("Accept", acceptJSON)}
+
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("http: encode request: %w", err)
+		}
+		headers = append(headers, network.WithHeader("Content-Type", "application/json"))
+	}
+
+	// Caller options come last so they can override the headers.
+	req := network.Request{Method: method, URL: url, Body: body}
+	resp, err := c.Do(ctx, req, append(headers, opts...)...)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+
+	if out != nil && len(resp.Body) > 0 {
+		if err := json.Unmarshal(resp.Body, out); err != nil {
+			return resp, fmt.Errorf("http: decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// GetJSON fetches url and decodes the response into a T. Non-2xx
+// responses return *HTTPError.
+func GetJSON[T any](ctx context.Context, c *Client, url string, opts ...network.Option) (T, error) {
+	var out T
+	_, err := DoJSON(ctx, c, "GET", url, nil, &out, slices.Concat(opts, []network.Option{network.WithStatusErrors()})...)
+	return out, err
+}
+
+// PostJSON posts body as JSON to url and decodes the response into a
+// Resp. Non-2xx responses return *HTTPError.
+func PostJSON[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...network.Option) (Resp, error) {
+	var out Resp
+	_, err := DoJSON(ctx, c, "POST", url, body, &out, slices.Concat(opts, []network.Option{network.WithStatusErrors()})...)
+	return out, err
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Total int    `json:"total"`
+}
+
+func jsonServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != acceptJSON {
+			t.Errorf("Expected Accept %q, got %q", acceptJSON, r.Header.Get("Accept"))
+		}
+		json.NewEncoder(w).Encode(order{ID: "1", Total: 42})
+	})
+	mux.HandleFunc("POST /orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", r.Header.Get("Content-Type"))
+		}
+		var o order
+		json.NewDecoder(r.Body).Decode(&o)
+		o.ID = "2"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(o)
+	})
+	mux.HandleFunc("GET /orders/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"https://example.com/not-found","title":"Not Found","status":404,"detail":"order missing does not exist","order_id":"missing"}`))
+	})
+	mux.HandleFunc("GET /orders/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"bad_id"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGetAndPostJSON(t *testing.T) {
+	srv := jsonServer(t)
+	defer srv.Close()
+	client := NewClient(time.Second)
+
+	got, err := GetJSON[order](context.Background(), client, srv.URL+"/orders/1")
+	if err != nil {
+		t.Fatalf("GetJSON failed: %v", err)
+	}
+	if got != (order{ID: "1", Total: 42}) {
+		t.Fatalf("Unexpected order %+v", got)
+	}
+
+	created, err := PostJSON[order, order](context.Background(), client, srv.URL+"/orders", order{Total: 7})
+	if err != nil {
+		t.Fatalf("PostJSON failed: %v", err)
+	}
+	if created != (order{ID: "2", Total: 7}) {
+		t.Fatalf("Unexpected order %+v", created)
+	}
+}
+
+func TestJSONProblemDetails(t *testing.T) {
+	srv := jsonServer(t)
+	defer srv.Close()
+	client := NewClient(time.Second)
+
+	_, err := GetJSON[order](context.Background(), client, srv.URL+"/orders/missing")
+	var herr *HTTPError
+	if !errors.As(err, &herr) {
+		t.Fatalf("Expected *HTTPError, got %v", err)
+	}
+	if herr.StatusCode != http.StatusNotFound || herr.Problem == nil {
+		t.Fatalf("Expected a 404 problem, got %+v", herr)
+	}
+	if herr.Problem.Detail != "order missing does not exist" || herr.Problem.Extensions["order_id"] != "missing" {
+		t.Fatalf("Unexpected problem %+v", herr.Problem)
+	}
+	if herr.Error() != "http: 404 Not Found: order missing does not exist" {
+		t.Fatalf("Unexpected message %q", herr.Error())
+	}
+}
+
+func TestJSONCustomErrorBody(t *testing.T) {
+	srv := jsonServer(t)
+	defer srv.Close()
+	client := NewClient(time.Second)
+
+	_, err := GetJSON[order](context.Background(), client, srv.URL+"/orders/broken")
+	var herr *HTTPError
+	if !errors.As(err, &herr) || herr.Problem != nil {
+		t.Fatalf("Expected a plain *HTTPError, got %v", err)
+	}
+	var body struct{ Code string }
+	if err := herr.Decode(&body); err != nil || body.Code != "bad_id" {
+		t.Fatalf("Expected to decode code bad_id, got %+v, %v", body, err)
+	}
+}
+
+func TestDoJSONStatusErrorsAreOptIn(t *testing.T) {
+	srv := jsonServer(t)
+	defer srv.Close()
+	client := NewClient(time.Second)
+
+	var out order
+	resp, err := DoJSON(context.Background(), client, "GET", srv.URL+"/orders/broken", nil, &out)
+	if err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected the 400 response without error, got %v, %v", resp, err)
+	}
+
+	resp, err = client.Get(context.Background(), srv.URL+"/orders/broken", network.WithStatusErrors())
+	var herr *HTTPError
+	if !errors.As(err, &herr) || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected the response and an *HTTPError, got %v, %v", resp, err)
+	}
+}
+
+func TestJSONHelpersLeaveCallerOptionsAlone(t *testing.T) {
+	srv := jsonServer(t)
+	defer srv.Close()
+	client := NewClient(time.Second)
+
+	opts := make([]network.Option, 1, 2)
+	opts[0] = network.WithHeader("X-Request-ID", "1")
+	GetJSON[order](context.Background(), client, srv.URL+"/orders/1", opts...)
+	PostJSON[order, order](context.Background(), client, srv.URL+"/orders", order{Total: 7}, opts...)
+	if opts[:2][1] != nil {
+		t.Fatal("Expected the spare capacity of the caller's options to stay unused")
+	}
+}
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the limit
+// set with MaxResponseSize.
+var ErrResponseTooLarge = errors.New("http: response body too large")
+
+// Middleware wraps a RoundTripper with extra behaviour. Middlewares must
+// not modify the request they are given; clone it first.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps rt with mws. The first middleware is the outermost, so it
+// sees the request first and the response last.
+func Chain(rt http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+// DefaultMiddleware returns the chain a Client uses unless it is replaced
+// with WithMiddlewareChain: Retry, Hedge, RateLimit, Bulkhead,
+// ConcurrencyLimit and CircuitBreaker, so every attempt and every hedged
+// copy goes through the limiters, the bulkhead and the breaker.
+func DefaultMiddleware() []Middleware {
+	return []Middleware{Retry(), Hedge(), RateLimit(), Bulkhead(), ConcurrencyLimit(), CircuitBreaker()}
+}
+
+// CircuitBreaker guards requests with the breaker set passed through
+// network.WithCircuitBreaker, one breaker per host. Transport errors and
+// 5xx responses count as failures.
+func CircuitBreaker() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			c := callFrom(req.Context())
+			if c == nil || c.config.Breakers == nil {
+				return next.RoundTrip(req)
+			}
+
+			done, err := c.config.Breakers.Get(req.URL.Host).Allow()
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			done(outcome(resp, err))
+			return resp, err
+		})
+	}
+}
+
+// outcome is what a round trip counts as for circuit breakers and outlier
+// ejection: its transport error, or an error for a 5xx status.
+func outcome(resp *http.Response, err error) error {
+	if err == nil && resp.StatusCode >= 500 {
+		return fmt.Errorf("http: status %d", resp.StatusCode)
+	}
+	return err
+}
+
+// RequestID sets header, X-Request-Id if empty, to a random ID on requests
+// that do not already carry one. Placed after Retry, each attempt gets its
+// own ID; placed before it, all attempts share one.
+func RequestID(header string) Middleware {
+	if header == "" {
+		header = "X-Request-Id"
+	}
+	return setHeader(func(req *http.Request) (string, string, error) {
+		if req.Header.Get(header) != "" {
+			return "", "", nil
+		}
+		var b [16]byte
+		rand.Read(b[:])
+		return header, hex.EncodeToString(b[:]), nil
+	})
+}
+
+// UserAgent sets the User-Agent header on requests that do not set one.
+func UserAgent(ua string) Middleware {
+	return setHeader(func(req *http.Request) (string, string, error) {
+		if req.Header.Get("User-Agent") != "" {
+			return "", "", nil
+		}
+		return "User-Agent", ua, nil
+	})
+}
+
+// Auth sets the Authorization header to the value returned by fn, which
+// is called for every request so it can hand out refreshed credentials.
+func Auth(fn func(ctx context.Context) (string, error)) Middleware {
+	return setHeader(func(req *http.Request) (string, string, error) {
+		v, err := fn(req.Context())
+		if err != nil {
+			return "", "", fmt.Errorf("http: auth: %w", err)
+		}
+		return "Authorization", v, nil
+	})
+}
+
+// BearerToken sets a static bearer token on every request.
+func BearerToken(token string) Middleware {
+	return Auth(func(context.Context) (string, error) {
+		return "Bearer " + token, nil
+	})
+}
+
+// setHeader builds a middleware that sets the header returned by fn on a
+// copy of the request. An empty key leaves the request unchanged.
+func setHeader(fn func(req *http.Request) (key, value string, err error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key, value, err := fn(req)
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			if key != "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(key, value)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Logging logs every round trip to l: completed ones at info level and
+// transport errors at warn level.
+func Logging(l *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			attrs := []any{
+				"method", req.Method,
+				"url", req.URL.Redacted(),
+				"duration", time.Since(start),
+			}
+			if err != nil {
+				l.WarnContext(req.Context(), "http: round trip failed", append(attrs, "error", err)...)
+				return nil, err
+			}
+			l.InfoContext(req.Context(), "http: round trip", append(attrs, "status", resp.StatusCode)...)
+			return resp, nil
+		})
+	}
+}
+
+// MaxResponseSize fails responses whose body is larger than n bytes with
+// ErrResponseTooLarge: up front when Content-Length says so, otherwise
+// when reading past the limit.
+func MaxResponseSize(n int64) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if resp.ContentLength > n {
+				discard(resp)
+				return nil, fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrResponseTooLarge, resp.ContentLength, n)
+			}
+			resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: n}
+			return resp, nil
+		})
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	// Read one byte past the limit to tell "exactly n" from "more than n".
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+// discard drains and closes a response that will not be returned, so its
+// connection can be reused.
+func discard(resp *http.Response) {
+	io.CopyN(io.Discard, resp.Body, 64<<10)
+	resp.Body.Close()
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,261 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/discovery"
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/retry"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := NewClient(time.Second,
+		WithTransport(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			order = append(order, "transport")
+			return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+		})),
+		WithMiddleware(mark("first"), mark("second")),
+	)
+
+	if _, err := client.Get(context.Background(), "http://example.invalid/"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "first,second,transport" {
+		t.Fatalf("Expected first,second,transport, got %s", got)
+	}
+}
+
+func TestBuiltinHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second, WithMiddleware(
+		RequestID(""),
+		UserAgent("orders/1.0"),
+		BearerToken("secret"),
+	))
+
+	if _, err := client.Get(context.Background(), srv.URL); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got.Get("X-Request-Id")) != 32 {
+		t.Fatalf("Expected a generated request ID, got %q", got.Get("X-Request-Id"))
+	}
+	if got.Get("User-Agent") != "orders/1.0" {
+		t.Fatalf("Expected User-Agent orders/1.0, got %q", got.Get("User-Agent"))
+	}
+	if got.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("Expected bearer token, got %q", got.Get("Authorization"))
+	}
+
+	// Caller-supplied values win.
+	client.Get(context.Background(), srv.URL, network.WithHeader("X-Request-Id", "abc"))
+	if got.Get("X-Request-Id") != "abc" {
+		t.Fatalf("Expected request ID abc to be kept, got %q", got.Get("X-Request-Id"))
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	errNoToken := errors.New("no token")
+	client := NewClient(time.Second, WithMiddleware(Auth(func(context.Context) (string, error) {
+		return "", errNoToken
+	})))
+
+	_, err := client.Get(context.Background(), "http://example.invalid/")
+	if !errors.Is(err, errNoToken) {
+		t.Fatalf("Expected auth error, got %v", err)
+	}
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	resp, err := client.Post(context.Background(), srv.URL, []byte("payload"),
+		network.WithHeader("Idempotency-Key", "order-1"),
+		network.WithRetryOnStatus(3, time.Millisecond, time.Millisecond, 1, http.StatusServiceUnavailable),
+	)
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if string(resp.Body) != "ok" {
+		t.Fatalf("Expected body ok, got %q", resp.Body)
+	}
+	for i, b := range bodies {
+		if b != "payload" {
+			t.Fatalf("Attempt %d sent body %q", i+1, b)
+		}
+	}
+}
+
+func TestRetryExhaustedReturnsLastResponse(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("slow down"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	resp, err := client.Get(context.Background(), srv.URL,
+		network.WithRetryOnStatus(2, time.Millisecond, time.Millisecond, 1, http.StatusTooManyRequests),
+	)
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, retry.ErrRetryableStatus) {
+		t.Fatalf("Expected a RetryError wrapping ErrRetryableStatus, got %v", err)
+	}
+	if len(retryErr.Attempts) != 2 || retryErr.Attempts[1].StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected 2 recorded attempts, got %+v", retryErr.Attempts)
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests || string(resp.Body) != "slow down" {
+		t.Fatalf("Expected the last response, got %+v", resp)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", hits.Load())
+	}
+}
+
+func TestRetryAttemptTimeoutDoesNotCutBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("late body"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	resp, err := client.Get(context.Background(), srv.URL, network.WithRetryStrategy(
+		retry.Policy{MaxAttempts: 2},
+		retry.WithAttemptTimeout(time.Second),
+	))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(resp.Body) != "late body" {
+		t.Fatalf("Expected late body, got %q", resp.Body)
+	}
+}
+
+func TestMiddlewareChainReplacesDefaults(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second, WithMiddlewareChain(UserAgent("no-retry")))
+	client.Get(context.Background(), srv.URL,
+		network.WithRetryOnStatus(3, time.Millisecond, time.Millisecond, 1, http.StatusServiceUnavailable),
+	)
+	if hits.Load() != 1 {
+		t.Fatalf("Expected retries to be disabled, got %d attempts", hits.Load())
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second, WithMiddleware(MaxResponseSize(10)))
+	for _, url := range []string{srv.URL, srv.URL + "?chunked=1"} {
+		if _, err := client.Get(context.Background(), url); !errors.Is(err, ErrResponseTooLarge) {
+			t.Fatalf("%s: expected ErrResponseTooLarge, got %v", url, err)
+		}
+	}
+
+	exact := NewClient(time.Second, WithMiddleware(MaxResponseSize(100)))
+	resp, err := exact.Get(context.Background(), srv.URL+"?chunked=1")
+	if err != nil || len(resp.Body) != 100 {
+		t.Fatalf("Expected a body at the limit to pass, got %v", err)
+	}
+}
+
+func TestClientTimeoutIsPerAttempt(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(100 * time.Millisecond)
+	_, err := client.Get(context.Background(), srv.URL,
+		network.WithRetry(2, time.Millisecond, time.Millisecond, 1),
+	)
+	if err != nil {
+		t.Fatalf("Expected the second attempt to succeed, got %v", err)
+	}
+}
+
+func TestDiscoveryRoutesLogicalHosts(t *testing.T) {
+	var hits [2]atomic.Int32
+	var servers [2]*httptest.Server
+	for i := range servers {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			if r.URL.Path != "/v1/orders" {
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+		}))
+		defer servers[i].Close()
+	}
+	addr := func(s *httptest.Server) string { return strings.TrimPrefix(s.URL, "http://") }
+
+	dir := discovery.New(discovery.Static(map[string][]string{
+		"orders": {addr(servers[0]), addr(servers[1])},
+	}))
+	client := NewClient(time.Second, WithMiddleware(Discovery(dir)))
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.Get(context.Background(), "http://orders/v1/orders"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if hits[0].Load() != 2 || hits[1].Load() != 2 {
+		t.Fatalf("Expected requests spread over both endpoints, got %d and %d", hits[0].Load(), hits[1].Load())
+	}
+
+	// Unknown hosts are left alone.
+	if _, err := client.Get(context.Background(), servers[0].URL+"/v1/orders"); err != nil {
+		t.Fatalf("Expected a plain URL to pass through, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FormFile is a file part of a multipart/form-data upload.
+type FormFile struct {
+	Field       string
+	FileName    string
+	ContentType string // defaults to application/octet-stream
+
+	// Open returns the file contents. It is called once per attempt.
+	Open func() (io.ReadCloser, error)
+}
+
+// FileFromPath returns a FormFile that uploads the file at path.
+func FileFromPath(field, path string) FormFile {
+	return FormFile{
+		Field:    field,
+		FileName: filepath.Base(path),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+}
+
+// MultipartRequest returns a StreamRequest that streams fields and files
+// as multipart/form-data without buffering them. Its GetBody re-encodes
+// the form, so it can be retried as long as the files can be reopened.
+func MultipartRequest(method, url string, fields map[string]string, files ...FormFile) StreamRequest {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	return StreamRequest{
+		Method:      method,
+		URL:         url,
+		ContentType: "multipart/form-data; boundary=" + boundary,
+		GetBody: func() (io.ReadCloser, error) {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.CloseWithError(writeMultipart(pw, boundary, fields, files))
+			}()
+			return pr, nil
+		},
+	}
+}
+
+func writeMultipart(w io.Writer, boundary string, fields map[string]string, files []FormFile) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := mw.WriteField(k, fields[k]); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		if err := writeFile(mw, f); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeFile(mw *multipart.Writer, f FormFile) error {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="`+quoteEscaper.Replace(f.Field)+
+		`"; filename="`+quoteEscaper.Replace(f.FileName)+`"`)
+	h.Set("Content-Type", contentType)
+
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(part, rc)
+	return err
+}
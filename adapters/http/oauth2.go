@@ -0,0 +1,55 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/festus/microkit/oauth2"
+)
+
+// OAuth2 authorizes requests with tokens from ts. When the server answers
+// 401 and ts is an oauth2.Refresher, such as an *oauth2.Source, the token
+// is refreshed and a replayable request is sent once more.
+func OAuth2(ts oauth2.TokenSource) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			token, err := ts.Token(ctx)
+			if err != nil {
+				closeBody(req)
+				return nil, fmt.Errorf("http: oauth2: %w", err)
+			}
+			resp, err := next.RoundTrip(authorize(req, token))
+			refresher, ok := ts.(oauth2.Refresher)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized || !ok || !replayable(req) {
+				return resp, err
+			}
+
+			// Whatever goes wrong from here, the 401 is the best answer.
+			token, err = refresher.Refresh(ctx, token)
+			if err != nil {
+				return resp, nil
+			}
+			r := req.Clone(ctx)
+			if req.GetBody != nil {
+				if r.Body, err = req.GetBody(); err != nil {
+					return resp, nil
+				}
+			}
+			discard(resp)
+			return next.RoundTrip(authorize(r, token))
+		})
+	}
+}
+
+// WithOAuth2 adds the OAuth2 middleware for ts to the client.
+func WithOAuth2(ts oauth2.TokenSource) Option {
+	return WithMiddleware(OAuth2(ts))
+}
+
+// authorize returns a copy of req carrying token.
+func authorize(req *http.Request, token *oauth2.Token) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return req
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/oauth2"
+)
+
+func TestOAuth2RefreshesOnUnauthorized(t *testing.T) {
+	var issued atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, issued.Add(1))
+	}))
+	defer tokenSrv.Close()
+
+	// The API has already revoked the first token.
+	var bodies []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer api.Close()
+
+	src := oauth2.ClientCredentials(oauth2.Config{TokenURL: tokenSrv.URL, ClientID: "orders", ClientSecret: "s3cret"})
+	client := NewClient(time.Second, WithOAuth2(src))
+
+	resp, err := client.Post(context.Background(), api.URL, []byte("payload"))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "ok" {
+		t.Fatalf("Expected the retried request to succeed, got %d %q", resp.StatusCode, resp.Body)
+	}
+	if len(bodies) != 2 || bodies[1] != "payload" {
+		t.Fatalf("Expected the body to be replayed, got %q", bodies)
+	}
+
+	// Later requests reuse the refreshed token.
+	if _, err := client.Get(context.Background(), api.URL); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got := issued.Load(); got != 2 {
+		t.Fatalf("Expected 2 token requests, got %d", got)
+	}
+}
+
+func TestOAuth2TokenError(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client"}`))
+	}))
+	defer tokenSrv.Close()
+
+	client := NewClient(time.Second, WithOAuth2(oauth2.ClientCredentials(oauth2.Config{TokenURL: tokenSrv.URL})))
+	_, err := client.Get(context.Background(), "http://127.0.0.1:1")
+
+	var oauthErr *oauth2.Error
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_client" {
+		t.Fatalf("Expected the token endpoint error, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/festus/microkit/breaker"
+	"github.com/festus/microkit/bulkhead"
+	"github.com/festus/microkit/ratelimit"
+)
+
+// RateLimit takes a token from the limiter passed through
+// network.WithRateLimit before every attempt, from the bucket of the
+// ratelimit.WithKey route key or else the request's host.
+func RateLimit() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			c := callFrom(req.Context())
+			if c == nil || c.config.RateLimiter == nil {
+				return next.RoundTrip(req)
+			}
+
+			key := ratelimit.Key(req.Context())
+			if key == "" {
+				key = req.URL.Host
+			}
+			if err := c.config.RateLimiter.Take(req.Context(), key); err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Bulkhead runs every attempt in a slot of the bulkhead passed through
+// network.WithBulkhead, holding it until the response body is closed.
+func Bulkhead() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			c := callFrom(req.Context())
+			if c == nil || c.config.Bulkhead == nil {
+				return next.RoundTrip(req)
+			}
+
+			release, err := c.config.Bulkhead.Acquire(req.Context())
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				release()
+				return nil, err
+			}
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+			return resp, nil
+		})
+	}
+}
+
+// ConcurrencyLimit holds a permit from the limiter passed through
+// network.WithConcurrencyLimit for every attempt until its response
+// headers arrive. Transport errors, 5xx and 429 responses count as drops.
+// Attempts rejected by a circuit breaker further down do not affect the
+// limit.
+func ConcurrencyLimit() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			c := callFrom(req.Context())
+			if c == nil || c.config.Concurrency == nil {
+				return next.RoundTrip(req)
+			}
+
+			permit, err := c.config.Concurrency.Acquire()
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			if errors.Is(err, breaker.ErrCircuitOpen) {
+				permit.Release()
+				return resp, err
+			}
+			permit.Done(overload(resp, err))
+			return resp, err
+		})
+	}
+}
+
+// overload is what a round trip counts as for concurrency limits: its
+// outcome, or an error for a 429 status.
+func overload(resp *http.Response, err error) error {
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		return errors.New("http: status 429")
+	}
+	return outcome(resp, err)
+}
+
+// rejected reports whether err means the attempt was refused locally by a
+// breaker, limiter or bulkhead, which retrying at once would not change.
+func rejected(err error) bool {
+	return errors.Is(err, breaker.ErrCircuitOpen) ||
+		errors.Is(err, bulkhead.ErrRejected) ||
+		errors.Is(err, ratelimit.ErrRateLimited) ||
+		errors.Is(err, ratelimit.ErrLimitExceeded)
+}
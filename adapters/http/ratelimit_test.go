@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/ratelimit"
+)
+
+func TestRateLimitFailFastIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second)
+	limit := network.WithRateLimit(ratelimit.New(0.1, 1, ratelimit.FailFast))
+	retry := network.WithRetry(3, time.Millisecond, time.Millisecond, 2)
+
+	if _, err := client.Get(context.Background(), server.URL, limit, retry); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	_, err := client.Get(context.Background(), server.URL, limit, retry)
+	if !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected 1 request to reach the server, got %d", calls.Load())
+	}
+
+	// Other route keys have their own bucket.
+	ctx := ratelimit.WithKey(context.Background(), "reports")
+	if _, err := client.Get(ctx, server.URL, limit); err != nil {
+		t.Fatalf("Expected a separate bucket for the route key, got %v", err)
+	}
+}
+
+func TestConcurrencyLimitShrinksOnOverload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second)
+	limiter := ratelimit.NewAdaptive(ratelimit.AdaptiveConfig{Initial: 10})
+	for i := 0; i < 5; i++ {
+		if _, err := client.Get(context.Background(), server.URL, network.WithConcurrencyLimit(limiter)); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if limiter.Limit() >= 10 {
+		t.Fatalf("Expected 503s to shrink the limit, got %d", limiter.Limit())
+	}
+	if limiter.InFlight() != 0 {
+		t.Fatalf("Expected every permit to be returned, got %d in flight", limiter.InFlight())
+	}
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/festus/microkit/retry"
+)
+
+// FailureKind classifies why an attempt failed.
+type FailureKind string
+
+const (
+	// FailureStatus is a response with a status listed in
+	// network.WithRetryOnStatus.
+	FailureStatus FailureKind = "status"
+	// FailureTimeout is an attempt that ran out of time. The server may
+	// have processed the request.
+	FailureTimeout FailureKind = "timeout"
+	// FailureConnReset is a connection closed or reset mid-request. The
+	// server may have processed the request.
+	FailureConnReset FailureKind = "connection_reset"
+	// FailureDial is a connection that could not be established, so the
+	// request was never sent.
+	FailureDial FailureKind = "dial"
+	// FailureOther is any other error.
+	FailureOther FailureKind = "other"
+)
+
+// Attempt is the outcome of one attempt of a retried request.
+type Attempt struct {
+	StatusCode int // zero when no response arrived
+	Kind       FailureKind
+	Err        error
+	Duration   time.Duration
+}
+
+// RetryError is returned when the Retry middleware gives up. It wraps the
+// last attempt's error and records every failed attempt. When the last
+// attempt got a response, the Client returns that response alongside it.
+type RetryError struct {
+	Attempts []Attempt
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("http: giving up after %d attempt(s): %v", len(e.Attempts), e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// Retry retries requests according to the network.WithRetry,
+// WithRetryStrategy and WithRetryOnStatus options of each call. Requests
+// without those options, or whose body cannot be replayed, pass through.
+//
+// Only idempotent methods are retried, unless the request carries an
+// Idempotency-Key header; other requests are retried only when the
+// connection could not be established. A Retry-After header on a
+// retryable response sets the next delay, capped by retry.WithMaxDelay.
+func Retry() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			c := callFrom(req.Context())
+			if c == nil || c.config.Retry == nil || !replayable(req) {
+				return next.RoundTrip(req)
+			}
+			config := c.config
+			safe := idempotent(req)
+
+			var resp *http.Response
+			var attempts []Attempt
+			err := retry.Do(req.Context(), config.Retry, func(ctx context.Context) error {
+				if resp != nil {
+					discard(resp)
+					resp = nil
+				}
+				start := time.Now()
+
+				r, release, err := attemptRequest(ctx, req, len(attempts)+1)
+				if err != nil {
+					return retry.Permanent(err)
+				}
+				res, err := next.RoundTrip(r)
+				cancel := release()
+				if err != nil {
+					cancel()
+					if ctx.Err() != nil {
+						err = ctx.Err()
+					}
+					kind := classify(err)
+					attempts = append(attempts, Attempt{Kind: kind, Err: err, Duration: time.Since(start)})
+					if rejected(err) || !(safe || kind == FailureDial) {
+						return retry.Permanent(err)
+					}
+					return err
+				}
+
+				res.Body = &releaseBody{ReadCloser: res.Body, release: cancel}
+				resp = res
+				if !retryableStatus(res.StatusCode, config.RetryStatusCodes) {
+					return nil
+				}
+
+				err = fmt.Errorf("%w: %d", retry.ErrRetryableStatus, res.StatusCode)
+				attempts = append(attempts, Attempt{
+					StatusCode: res.StatusCode,
+					Kind:       FailureStatus,
+					Err:        err,
+					Duration:   time.Since(start),
+				})
+				if !safe {
+					return retry.Permanent(err)
+				}
+				if d, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+					return retry.RetryAfter(err, d)
+				}
+				return err
+			}, config.RetryOptions...)
+
+			if err == nil {
+				return resp, nil
+			}
+			err = &RetryError{Attempts: attempts, Err: err}
+			if resp != nil && len(attempts) > 0 && attempts[len(attempts)-1].Kind == FailureStatus {
+				c.err = err
+				return resp, nil
+			}
+			if resp != nil {
+				discard(resp)
+			}
+			return nil, err
+		})
+	}
+}
+
+// idempotent reports whether req may be sent more than once without
+// changing the outcome.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryableStatus(code int, codes []int) bool {
+	for _, c := range codes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// classify tells apart the transport failures that matter for retries.
+func classify(err error) FailureKind {
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return FailureDial
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return FailureConnReset
+	}
+	return FailureOther
+}
+
+// parseRetryAfter parses a Retry-After header given as delay seconds or
+// as an HTTP date relative to now.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// replayable reports whether req can be sent more than once.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// attemptRequest returns a copy of req for the given attempt. The copy is
+// cancelled if ctx, the attempt's context, ends before a response
+// arrives. Once it has, the response body outlives ctx: release detaches
+// the copy from ctx and returns a function that cancels it, to be called
+// when the body is closed.
+func attemptRequest(ctx context.Context, req *http.Request, attempt int) (*http.Request, func() context.CancelFunc, error) {
+	reqCtx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(ctx, cancel)
+	release := func() context.CancelFunc {
+		stop()
+		return cancel
+	}
+
+	r := req.Clone(reqCtx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		r.Body = body
+	}
+	return r, release, nil
+}
+
+// releaseBody runs release when the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release context.CancelFunc
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/retry"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRetryAfterHonoredAndCapped(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	client := NewClient(time.Second)
+	// WithRetryOnStatus supplies the status codes, WithRetryStrategy the
+	// policy and a hook to observe the delay.
+	_, err := client.Get(context.Background(), srv.URL,
+		network.WithRetryOnStatus(2, time.Millisecond, 20*time.Millisecond, 1, http.StatusServiceUnavailable),
+		network.WithRetryStrategy(
+			retry.Policy{MaxAttempts: 2, Backoff: retry.Constant{Delay: time.Millisecond}},
+			retry.WithMaxDelay(20*time.Millisecond),
+			retry.OnRetry(func(attempt int, err error, delay time.Duration) {
+				delays = append(delays, delay)
+			}),
+		),
+	)
+	if err != nil || hits.Load() != 2 {
+		t.Fatalf("Expected success on the second attempt, got %v after %d", err, hits.Load())
+	}
+	if len(delays) != 1 || delays[0] != 20*time.Millisecond {
+		t.Fatalf("Expected Retry-After capped at 20ms, got %v", delays)
+	}
+}
+
+func TestRetryOnlyIdempotent(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	retryOn503 := network.WithRetryOnStatus(3, time.Millisecond, time.Millisecond, 1, http.StatusServiceUnavailable)
+
+	tests := []struct {
+		name string
+		do   func() (*network.Response, error)
+		want int32
+	}{
+		{"GET", func() (*network.Response, error) {
+			return client.Get(context.Background(), srv.URL, retryOn503)
+		}, 3},
+		{"POST", func() (*network.Response, error) {
+			return client.Post(context.Background(), srv.URL, []byte("{}"), retryOn503)
+		}, 1},
+		{"POST with Idempotency-Key", func() (*network.Response, error) {
+			return client.Post(context.Background(), srv.URL, []byte("{}"), retryOn503,
+				network.WithHeader("Idempotency-Key", "k1"))
+		}, 3},
+	}
+	for _, tt := range tests {
+		hits.Store(0)
+		resp, err := tt.do()
+		if hits.Load() != tt.want {
+			t.Errorf("%s: expected %d attempts, got %d", tt.name, tt.want, hits.Load())
+		}
+		if resp == nil || resp.StatusCode != http.StatusServiceUnavailable || !errors.Is(err, retry.ErrRetryableStatus) {
+			t.Errorf("%s: expected the 503 response and a retry error, got %v, %v", tt.name, resp, err)
+		}
+	}
+}
+
+func TestRetryClassifiesTransportFailures(t *testing.T) {
+	// A closed listener makes every dial fail.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+	ln.Close()
+
+	client := NewClient(time.Second)
+	_, err = client.Post(context.Background(), url, []byte("{}"),
+		network.WithRetry(3, time.Millisecond, time.Millisecond, 1))
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("Expected a RetryError, got %v", err)
+	}
+	if len(retryErr.Attempts) != 3 {
+		t.Fatalf("Expected dial failures to be retried even for POST, got %d attempts", len(retryErr.Attempts))
+	}
+	for _, a := range retryErr.Attempts {
+		if a.Kind != FailureDial {
+			t.Fatalf("Expected dial failures, got %s: %v", a.Kind, a.Err)
+		}
+	}
+
+	// A server that hangs past the client timeout.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	client = NewClient(20 * time.Millisecond)
+	_, err = client.Post(context.Background(), srv.URL, []byte("{}"),
+		network.WithRetry(3, time.Millisecond, time.Millisecond, 1))
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("Expected a RetryError, got %v", err)
+	}
+	if len(retryErr.Attempts) != 1 || retryErr.Attempts[0].Kind != FailureTimeout {
+		t.Fatalf("Expected a single timed-out POST attempt, got %+v", retryErr.Attempts)
+	}
+}
@@ -0,0 +1,462 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureAlgorithm names the signing scheme in signatures and canonical
+// requests.
+const SignatureAlgorithm = "HMAC-SHA256"
+
+var (
+	ErrSignatureMissing   = errors.New("http: request is not signed")
+	ErrSignatureInvalid   = errors.New("http: invalid request signature")
+	ErrSignatureExpired   = errors.New("http: request signature outside the allowed clock skew")
+	ErrSignatureReplayed  = errors.New("http: request signature replayed")
+	ErrUnknownKey         = errors.New("http: unknown signing key")
+	ErrSignedBodyTooLarge = errors.New("http: signed request body too large")
+)
+
+// Signature is what a signed request carries besides its content.
+type Signature struct {
+	KeyID     string
+	Timestamp time.Time
+	Nonce     string
+
+	// Headers are the signed headers, lower case and sorted.
+	Headers []string
+
+	MAC []byte
+}
+
+// SignatureFormat writes a signature into request headers and reads it
+// back.
+type SignatureFormat interface {
+	Write(h http.Header, sig *Signature)
+	// Read returns ErrSignatureMissing if h carries no signature.
+	Read(h http.Header) (*Signature, error)
+}
+
+// CompactFormat carries the signature in a single header, "Signature"
+// unless set:
+//
+//	HMAC-SHA256 keyId=k1,ts=1700000000,nonce=9f86d0...,headers=content-type;host,sig=<base64url>
+type CompactFormat struct {
+	Header string
+}
+
+func (f CompactFormat) header() string {
+	if f.Header == "" {
+		return "Signature"
+	}
+	return f.Header
+}
+
+func (f CompactFormat) Write(h http.Header, sig *Signature) {
+	h.Set(f.header(), fmt.Sprintf("%s keyId=%s,ts=%d,nonce=%s,headers=%s,sig=%s",
+		SignatureAlgorithm, sig.KeyID, sig.Timestamp.Unix(), sig.Nonce,
+		strings.Join(sig.Headers, ";"), base64.RawURLEncoding.EncodeToString(sig.MAC)))
+}
+
+func (f CompactFormat) Read(h http.Header) (*Signature, error) {
+	v := h.Get(f.header())
+	if v == "" {
+		return nil, ErrSignatureMissing
+	}
+	alg, params, ok := strings.Cut(v, " ")
+	if !ok || alg != SignatureAlgorithm {
+		return nil, fmt.Errorf("%w: unsupported algorithm", ErrSignatureInvalid)
+	}
+	fields := make(map[string]string)
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		fields[k] = v
+	}
+	return parseSignature(fields["keyId"], fields["ts"], fields["nonce"], fields["headers"], fields["sig"])
+}
+
+// SplitFormat carries each part of the signature in its own header, named
+// after Prefix, "X-Signature" unless set: X-Signature-Key-Id,
+// X-Signature-Timestamp, X-Signature-Nonce, X-Signature-Headers and
+// X-Signature for the MAC.
+type SplitFormat struct {
+	Prefix string
+}
+
+func (f SplitFormat) prefix() string {
+	if f.Prefix == "" {
+		return "X-Signature"
+	}
+	return f.Prefix
+}
+
+func (f SplitFormat) Write(h http.Header, sig *Signature) {
+	p := f.prefix()
+	h.Set(p+"-Key-Id", sig.KeyID)
+	h.Set(p+"-Timestamp", strconv.FormatInt(sig.Timestamp.Unix(), 10))
+	h.Set(p+"-Nonce", sig.Nonce)
+	h.Set(p+"-Headers", strings.Join(sig.Headers, ";"))
+	h.Set(p, base64.RawURLEncoding.EncodeToString(sig.MAC))
+}
+
+func (f SplitFormat) Read(h http.Header) (*Signature, error) {
+	p := f.prefix()
+	if h.Get(p) == "" {
+		return nil, ErrSignatureMissing
+	}
+	return parseSignature(h.Get(p+"-Key-Id"), h.Get(p+"-Timestamp"), h.Get(p+"-Nonce"), h.Get(p+"-Headers"), h.Get(p))
+}
+
+func parseSignature(keyID, ts, nonce, headers, mac string) (*Signature, error) {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || keyID == "" || nonce == "" {
+		return nil, fmt.Errorf("%w: malformed", ErrSignatureInvalid)
+	}
+	sig := &Signature{KeyID: keyID, Timestamp: time.Unix(secs, 0), Nonce: nonce}
+	if headers != "" {
+		sig.Headers = strings.Split(headers, ";")
+	}
+	if sig.MAC, err = base64.RawURLEncoding.DecodeString(mac); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrSignatureInvalid)
+	}
+	return sig, nil
+}
+
+// SigningConfig configures SignRequests.
+type SigningConfig struct {
+	KeyID string
+	Key   []byte
+
+	// Headers are signed along with the method, path, query, body,
+	// timestamp and nonce. Host and Content-Type are always signed.
+	Headers []string
+
+	// Format defaults to CompactFormat.
+	Format SignatureFormat
+}
+
+// SignRequests signs every request with an HMAC-SHA256 over its canonical
+// form. Placed inside Retry, as WithSigning does, each attempt gets a new
+// timestamp and nonce. Bodies that cannot be replayed are read into
+// memory to be hashed.
+//
+// The canonical request is the following lines joined by "\n":
+//
+//	HMAC-SHA256
+//	<timestamp in Unix seconds>
+//	<nonce>
+//	<method>
+//	<escaped path>
+//	<query, keys and values sorted and escaped>
+//	<name>:<values joined by ","> for each signed header
+//	<signed header names joined by ";">
+//	<hex SHA-256 of the body>
+func SignRequests(cfg SigningConfig) Middleware {
+	headers := signedHeaders(append([]string{"host", "content-type"}, cfg.Headers...))
+	format := cfg.Format
+	if format == nil {
+		format = CompactFormat{}
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := requestBody(req)
+			if err != nil {
+				closeBody(req)
+				return nil, fmt.Errorf("http: sign: %w", err)
+			}
+			var nonce [16]byte
+			rand.Read(nonce[:])
+
+			sig := &Signature{
+				KeyID:     cfg.KeyID,
+				Timestamp: time.Now(),
+				Nonce:     hex.EncodeToString(nonce[:]),
+				Headers:   headers,
+			}
+			req = req.Clone(req.Context())
+			if req.GetBody == nil && body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			sig.MAC = signatureMAC(cfg.Key, canonicalRequest(req, req.Host, body, sig))
+			format.Write(req.Header, sig)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// WithSigning adds the SignRequests middleware for cfg to the client.
+func WithSigning(cfg SigningConfig) Option {
+	return WithMiddleware(SignRequests(cfg))
+}
+
+// requestBody returns the body of req without consuming it when it can
+// be replayed, and reads it otherwise.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		defer req.Body.Close()
+		return io.ReadAll(req.Body)
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// KeyLookup returns the key for a key ID, so keys can be rotated by
+// accepting the old and new IDs for a while. It returns an error for
+// unknown or retired IDs.
+type KeyLookup func(ctx context.Context, keyID string) ([]byte, error)
+
+// StaticKeys looks keys up in a fixed map.
+func StaticKeys(keys map[string][]byte) KeyLookup {
+	return func(_ context.Context, keyID string) ([]byte, error) {
+		key, ok := keys[keyID]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	}
+}
+
+// NonceStore remembers nonces so that replayed requests are rejected.
+// Share one store between instances, for example in Redis, to catch
+// replays across them.
+type NonceStore interface {
+	// Add records nonce until expiry, reporting false if it is already
+	// recorded.
+	Add(ctx context.Context, nonce string, expiry time.Time) (bool, error)
+}
+
+// VerifierConfig configures a Verifier. Zero values use the documented
+// defaults.
+type VerifierConfig struct {
+	Keys KeyLookup
+
+	// Headers must be among the signed headers. Host is always required.
+	Headers []string
+
+	// MaxSkew is how far a request's timestamp may be from the server's
+	// clock, either way. Defaults to 5 minutes. Nonces are remembered for
+	// as long as their request is within it, so replays are caught for
+	// the whole window.
+	MaxSkew time.Duration
+
+	// Nonces defaults to a store in memory.
+	Nonces NonceStore
+
+	// Format defaults to CompactFormat.
+	Format SignatureFormat
+
+	// MaxBodySize is the largest body read to check its hash. Defaults
+	// to 10 MiB.
+	MaxBodySize int64
+}
+
+// Verifier checks signatures made by SignRequests.
+type Verifier struct {
+	cfg      VerifierConfig
+	required []string
+	now      func() time.Time
+}
+
+func NewVerifier(cfg VerifierConfig) *Verifier {
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+	if cfg.Nonces == nil {
+		cfg.Nonces = &memoryNonces{seen: make(map[string]time.Time)}
+	}
+	if cfg.Format == nil {
+		cfg.Format = CompactFormat{}
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 10 << 20
+	}
+	return &Verifier{
+		cfg:      cfg,
+		required: signedHeaders(append([]string{"host"}, cfg.Headers...)),
+		now:      time.Now,
+	}
+}
+
+// Verify checks the signature of r. It reads the body and replaces it
+// with a copy, so handlers can still read it.
+func (v *Verifier) Verify(r *http.Request) error {
+	sig, err := v.cfg.Format.Read(r.Header)
+	if err != nil {
+		return err
+	}
+	for _, h := range v.required {
+		if !slices.Contains(sig.Headers, h) {
+			return fmt.Errorf("%w: header %s is not signed", ErrSignatureInvalid, h)
+		}
+	}
+	now := v.now()
+	if d := now.Sub(sig.Timestamp); d > v.cfg.MaxSkew || d < -v.cfg.MaxSkew {
+		return ErrSignatureExpired
+	}
+	key, err := v.cfg.Keys(r.Context(), sig.KeyID)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, sig.KeyID)
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = io.ReadAll(io.LimitReader(r.Body, v.cfg.MaxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return fmt.Errorf("http: read signed body: %w", err)
+		}
+		if int64(len(body)) > v.cfg.MaxBodySize {
+			return fmt.Errorf("%w: over %d bytes", ErrSignedBodyTooLarge, v.cfg.MaxBodySize)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	want := signatureMAC(key, canonicalRequest(r, r.Host, body, sig))
+	if !hmac.Equal(sig.MAC, want) {
+		return ErrSignatureInvalid
+	}
+
+	// Nonces are only recorded for valid signatures, so forged requests
+	// cannot fill the store.
+	fresh, err := v.cfg.Nonces.Add(r.Context(), sig.KeyID+":"+sig.Nonce, sig.Timestamp.Add(v.cfg.MaxSkew))
+	if err != nil {
+		return fmt.Errorf("http: nonce store: %w", err)
+	}
+	if !fresh {
+		return ErrSignatureReplayed
+	}
+	return nil
+}
+
+// VerifySignatures serves only requests whose signature v accepts,
+// answering the others with 401 Unauthorized, or 413 Request Entity Too
+// Large for oversized bodies.
+func VerifySignatures(v *Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			code := http.StatusUnauthorized
+			if errors.Is(err, ErrSignedBodyTooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canonicalRequest builds the string signed for r, as documented on
+// SignRequests.
+func canonicalRequest(r *http.Request, host string, body []byte, sig *Signature) string {
+	var b strings.Builder
+	b.WriteString(SignatureAlgorithm + "\n")
+	b.WriteString(strconv.FormatInt(sig.Timestamp.Unix(), 10) + "\n")
+	b.WriteString(sig.Nonce + "\n")
+	b.WriteString(r.Method + "\n")
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path + "\n")
+	b.WriteString(canonicalQuery(r.URL.RawQuery) + "\n")
+	for _, h := range sig.Headers {
+		value := strings.Join(r.Header.Values(h), ",")
+		if h == "host" {
+			value = host
+			if value == "" {
+				value = r.URL.Host
+			}
+		}
+		b.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	b.WriteString(strings.Join(sig.Headers, ";") + "\n")
+	sum := sha256.Sum256(body)
+	b.WriteString(hex.EncodeToString(sum[:]))
+	return b.String()
+}
+
+func canonicalQuery(raw string) string {
+	values, _ := url.ParseQuery(raw)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := slices.Clone(values[k])
+		slices.Sort(vs)
+		for _, v := range vs {
+			parts = append(parts, url.PathEscape(k)+"="+url.PathEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// signedHeaders returns names lower cased, sorted and without duplicates.
+func signedHeaders(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		out = append(out, strings.ToLower(n))
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+func signatureMAC(key []byte, canonical string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(canonical))
+	return mac.Sum(nil)
+}
+
+// memoryNonces is the default NonceStore. Expired nonces are dropped as
+// new ones are added.
+type memoryNonces struct {
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	prune time.Time
+}
+
+func (m *memoryNonces) Add(_ context.Context, nonce string, expiry time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.After(m.prune) {
+		for n, exp := range m.seen {
+			if now.After(exp) {
+				delete(m.seen, n)
+			}
+		}
+		m.prune = now.Add(time.Minute)
+	}
+	if exp, ok := m.seen[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.seen[nonce] = expiry
+	return true, nil
+}
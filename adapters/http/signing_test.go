@@ -0,0 +1,179 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/discovery"
+	"github.com/festus/microkit/network"
+)
+
+// signedRequest signs a request through SignRequests and returns it as a
+// server would receive it.
+func signedRequest(t *testing.T, cfg SigningConfig, method, url string, body []byte) *http.Request {
+	t.Helper()
+	var signed *http.Request
+	rt := SignRequests(cfg)(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		signed = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	r := httptest.NewRequest(method, url, bytes.NewReader(body))
+	r.Header = signed.Header.Clone()
+	return r
+}
+
+func TestSignedRequestsVerify(t *testing.T) {
+	var got []byte
+	v := NewVerifier(VerifierConfig{
+		Keys:    StaticKeys(map[string][]byte{"k1": []byte("old"), "k2": []byte("new")}),
+		Headers: []string{"X-Tenant"},
+	})
+	srv := httptest.NewServer(VerifySignatures(v, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+	})))
+	defer srv.Close()
+
+	for _, key := range []SigningConfig{
+		{KeyID: "k1", Key: []byte("old"), Headers: []string{"X-Tenant"}},
+		{KeyID: "k2", Key: []byte("new"), Headers: []string{"x-tenant"}, Format: CompactFormat{}},
+	} {
+		client := NewClient(time.Second, WithSigning(key))
+		resp, err := client.Post(context.Background(), srv.URL+"/orders?b=2&a=1&a=0", []byte(`{"id":1}`),
+			network.WithHeader("X-Tenant", "acme"))
+		if err != nil {
+			t.Fatalf("Post failed: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || string(got) != `{"id":1}` {
+			t.Fatalf("Expected %s to verify with the body intact, got %d %q", key.KeyID, resp.StatusCode, got)
+		}
+	}
+
+	// Without the required header signed, the request is refused.
+	client := NewClient(time.Second, WithSigning(SigningConfig{KeyID: "k2", Key: []byte("new")}))
+	resp, _ := client.Get(context.Background(), srv.URL)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestSignedRequestsVerifyThroughDiscovery(t *testing.T) {
+	v := NewVerifier(VerifierConfig{Keys: StaticKeys(map[string][]byte{"k1": []byte("secret")})})
+	srv := httptest.NewServer(VerifySignatures(v, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer srv.Close()
+
+	dir := discovery.New(discovery.Static(map[string][]string{
+		"orders": {strings.TrimPrefix(srv.URL, "http://")},
+	}))
+	sign := SignRequests(SigningConfig{KeyID: "k1", Key: []byte("secret")})
+
+	// The signer sees the logical host in one order and the endpoint in
+	// the other; both must verify.
+	for name, mws := range map[string][]Middleware{
+		"signed first":    {sign, Discovery(dir)},
+		"discovery first": {Discovery(dir), sign},
+	} {
+		client := NewClient(time.Second, WithMiddlewareChain(mws...))
+		resp, err := client.Post(context.Background(), "http://orders/v1/orders", []byte(`{"id":1}`))
+		if err != nil {
+			t.Fatalf("%s: Post failed: %v", name, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: Expected the signature to verify at the endpoint, got %d: %s", name, resp.StatusCode, resp.Body)
+		}
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	cfg := SigningConfig{KeyID: "k1", Key: []byte("secret")}
+	v := NewVerifier(VerifierConfig{Keys: StaticKeys(map[string][]byte{"k1": []byte("secret")})})
+
+	tests := map[string]func(r *http.Request) *http.Request{
+		"body": func(r *http.Request) *http.Request {
+			r.Body = io.NopCloser(bytes.NewReader([]byte(`{"amount":1000}`)))
+			return r
+		},
+		"query": func(r *http.Request) *http.Request {
+			r.URL.RawQuery = "amount=1000"
+			return r
+		},
+		"method": func(r *http.Request) *http.Request {
+			r.Method = http.MethodPut
+			return r
+		},
+		"content type": func(r *http.Request) *http.Request {
+			r.Header.Set("Content-Type", "text/plain")
+			return r
+		},
+	}
+	for name, tamper := range tests {
+		r := tamper(signedRequest(t, cfg, "POST", "http://pay.internal/charge?amount=10", []byte(`{"amount":10}`)))
+		if err := v.Verify(r); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("%s: expected ErrSignatureInvalid, got %v", name, err)
+		}
+	}
+
+	r := signedRequest(t, SigningConfig{KeyID: "k9", Key: []byte("secret")}, "GET", "http://pay.internal/", nil)
+	if err := v.Verify(r); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	if err := v.Verify(httptest.NewRequest("GET", "http://pay.internal/", nil)); !errors.Is(err, ErrSignatureMissing) {
+		t.Errorf("Expected ErrSignatureMissing, got %v", err)
+	}
+}
+
+func TestVerifyReplayAndSkew(t *testing.T) {
+	cfg := SigningConfig{KeyID: "k1", Key: []byte("secret"), Format: SplitFormat{Prefix: "X-Mk-Signature"}}
+	v := NewVerifier(VerifierConfig{
+		Keys:    StaticKeys(map[string][]byte{"k1": []byte("secret")}),
+		MaxSkew: time.Minute,
+		Format:  SplitFormat{Prefix: "X-Mk-Signature"},
+	})
+
+	r := signedRequest(t, cfg, "POST", "http://pay.internal/charge?b=1&a=2", []byte("x"))
+	if r.Header.Get("X-Mk-Signature-Nonce") == "" {
+		t.Fatalf("Expected split signature headers, got %v", r.Header)
+	}
+	replay := r.Clone(context.Background())
+	replay.Body = io.NopCloser(bytes.NewReader([]byte("x")))
+	if err := v.Verify(r); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := v.Verify(replay); !errors.Is(err, ErrSignatureReplayed) {
+		t.Fatalf("Expected ErrSignatureReplayed, got %v", err)
+	}
+
+	for _, offset := range []time.Duration{2 * time.Minute, -2 * time.Minute} {
+		v.now = func() time.Time { return time.Now().Add(offset) }
+		r := signedRequest(t, cfg, "GET", "http://pay.internal/", nil)
+		if err := v.Verify(r); !errors.Is(err, ErrSignatureExpired) {
+			t.Fatalf("Expected ErrSignatureExpired with clock offset %v, got %v", offset, err)
+		}
+	}
+}
+
+func TestVerifySignaturesBodyLimit(t *testing.T) {
+	cfg := SigningConfig{KeyID: "k1", Key: []byte("secret")}
+	v := NewVerifier(VerifierConfig{Keys: StaticKeys(map[string][]byte{"k1": []byte("secret")}), MaxBodySize: 4})
+	h := VerifySignatures(v, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatal("Handler must not run")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, cfg, "POST", "http://pay.internal/", []byte("too large")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413, got %d", w.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/festus/microkit/network"
+)
+
+// StreamRequest is a request whose body is streamed from a reader rather
+// than held in memory.
+type StreamRequest struct {
+	Method  string
+	URL     string
+	Headers network.Header
+
+	// Body is the request body, or nil when GetBody should supply it.
+	Body io.Reader
+
+	// GetBody returns a fresh copy of the body so the request can be
+	// retried. It is set automatically for *bytes.Buffer, *bytes.Reader
+	// and *strings.Reader bodies; other bodies without it are sent once.
+	GetBody func() (io.ReadCloser, error)
+
+	// ContentLength is the body size, or zero if unknown.
+	ContentLength int64
+	ContentType   string
+
+	// OnProgress is called as the request body is sent and as the
+	// response body is read.
+	OnProgress func(Progress)
+
+	// MaxBodySize fails the response with ErrResponseTooLarge once its
+	// body exceeds this many bytes. Zero means no limit.
+	MaxBodySize int64
+}
+
+// StreamResponse is a response whose body has not been read. Callers must
+// close Body. The fields match network.Response, except that Elapsed stops
+// when the headers arrive.
+type StreamResponse struct {
+	StatusCode    int
+	Headers       network.Header
+	Body          io.ReadCloser
+	Proto         string
+	ContentLength int64
+	URL           string
+	Elapsed       time.Duration
+}
+
+// Direction tells uploads and downloads apart in progress reports.
+type Direction int
+
+const (
+	Upload Direction = iota
+	Download
+)
+
+// Progress reports how many bytes of a body have been transferred. Total
+// is -1 when the size is unknown. Retried uploads start again from zero.
+type Progress struct {
+	Direction Direction
+	Bytes     int64
+	Total     int64
+}
+
+// errorBodyLimit bounds how much of a non-2xx streamed body is read into
+// an *HTTPError.
+const errorBodyLimit = 64 << 10
+
+// Stream sends sr through the middleware chain and returns the response
+// with its body unread. With network.WithStatusErrors, a non-2xx response
+// is returned with an *HTTPError holding up to 64 KiB of its body, which
+// is also left readable on the response.
+func (c *Client) Stream(ctx context.Context, sr StreamRequest, opts ...network.Option) (*StreamResponse, error) {
+	body := sr.Body
+	if body == nil && sr.GetBody != nil {
+		rc, err := sr.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("http: get body: %w", err)
+		}
+		body = rc
+	}
+
+	req, call, err := newRequest(ctx, sr.Method, sr.URL, body, sr.Headers, opts)
+	if err != nil {
+		if rc, ok := body.(io.Closer); ok {
+			rc.Close()
+		}
+		return nil, err
+	}
+	if sr.GetBody != nil {
+		req.GetBody = sr.GetBody
+	}
+	if sr.ContentLength > 0 {
+		req.ContentLength = sr.ContentLength
+	}
+	if sr.ContentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", sr.ContentType)
+	}
+	if sr.OnProgress != nil {
+		trackUpload(req, sr.OnProgress)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	meta := newResponse(resp, start)
+	storeMetadata(call.config, resp)
+
+	if sr.MaxBodySize > 0 {
+		if resp.ContentLength > sr.MaxBodySize {
+			discard(resp)
+			return nil, fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrResponseTooLarge, resp.ContentLength, sr.MaxBodySize)
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: sr.MaxBodySize}
+	}
+	if sr.OnProgress != nil {
+		resp.Body = &progressBody{ReadCloser: resp.Body, dir: Download, total: knownSize(resp.ContentLength), fn: sr.OnProgress}
+	}
+
+	out := &StreamResponse{
+		StatusCode:    meta.StatusCode,
+		Headers:       meta.Headers,
+		Body:          resp.Body,
+		Proto:         meta.Proto,
+		ContentLength: meta.ContentLength,
+		URL:           meta.URL,
+		Elapsed:       meta.Elapsed,
+	}
+	if !call.config.StatusErrors || (resp.StatusCode >= 200 && resp.StatusCode <= 299) {
+		return out, call.err
+	}
+
+	// Buffer the start of the error body for the *HTTPError.
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+	out.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+
+	meta.Body = head
+	return out, call.result(meta)
+}
+
+// trackUpload reports progress on req's body and on every copy of it made
+// for retries.
+func trackUpload(req *http.Request, fn func(Progress)) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	size := knownSize(req.ContentLength)
+	req.Body = &progressBody{ReadCloser: req.Body, dir: Upload, total: size, fn: fn}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			rc, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &progressBody{ReadCloser: rc, dir: Upload, total: size, fn: fn}, nil
+		}
+	}
+}
+
+func knownSize(contentLength int64) int64 {
+	if contentLength <= 0 {
+		return -1
+	}
+	return contentLength
+}
+
+type progressBody struct {
+	io.ReadCloser
+	dir   Direction
+	total int64
+	n     int64
+	fn    func(Progress)
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.n += int64(n)
+		b.fn(Progress{Direction: b.dir, Bytes: b.n, Total: b.total})
+	}
+	return n, err
+}
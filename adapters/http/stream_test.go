@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/network"
+)
+
+func TestStreamDownload(t *testing.T) {
+	payload := strings.Repeat("x", 1<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		io.WriteString(w, payload)
+	}))
+	defer srv.Close()
+
+	client := NewClient(5 * time.Second)
+
+	var last Progress
+	resp, err := client.Stream(context.Background(), StreamRequest{
+		Method:     "GET",
+		URL:        srv.URL,
+		OnProgress: func(p Progress) { last = p },
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil || n != int64(len(payload)) {
+		t.Fatalf("Expected %d bytes, got %d: %v", len(payload), n, err)
+	}
+	if last.Direction != Download || last.Bytes != n || last.Total != n {
+		t.Fatalf("Unexpected final progress %+v", last)
+	}
+
+	_, err = client.Stream(context.Background(), StreamRequest{Method: "GET", URL: srv.URL, MaxBodySize: 1024})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("Expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestStreamUploadRetriesWithGetBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	open := func() (io.ReadCloser, error) {
+		// Hide the concrete type so net/http cannot rewind it by itself.
+		return io.NopCloser(struct{ io.Reader }{strings.NewReader("chunk")}), nil
+	}
+	var uploads atomic.Int32
+	client := NewClient(time.Second)
+	resp, err := client.Stream(context.Background(), StreamRequest{
+		Method:  "PUT",
+		URL:     srv.URL,
+		GetBody: open,
+		OnProgress: func(p Progress) {
+			if p.Direction == Upload && p.Bytes == 5 {
+				uploads.Add(1)
+			}
+		},
+	}, network.WithRetryOnStatus(2, time.Millisecond, time.Millisecond, 1, http.StatusServiceUnavailable))
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "chunk" || bodies[1] != "chunk" {
+		t.Fatalf("Expected the body to be sent twice, got %q", bodies)
+	}
+	if uploads.Load() != 2 {
+		t.Fatalf("Expected upload progress for both attempts, got %d", uploads.Load())
+	}
+}
+
+func TestStreamStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "denied")
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	resp, err := client.Stream(context.Background(), StreamRequest{Method: "GET", URL: srv.URL}, network.WithStatusErrors())
+	var herr *HTTPError
+	if !errors.As(err, &herr) || string(herr.Body) != "denied" {
+		t.Fatalf("Expected an *HTTPError with the body, got %v", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "denied" {
+		t.Fatalf("Expected the body to stay readable, got %q", b)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+			return
+		}
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		f, hdr, err := r.FormFile("report")
+		if err != nil {
+			t.Errorf("FormFile: %v", err)
+			return
+		}
+		defer f.Close()
+		b, _ := io.ReadAll(f)
+		io.WriteString(w, r.FormValue("owner")+":"+hdr.Filename+":"+string(b))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "q1.csv")
+	os.WriteFile(path, []byte("a,b\n1,2\n"), 0o600)
+
+	client := NewClient(time.Second)
+	req := MultipartRequest("POST", srv.URL, map[string]string{"owner": "finance"}, FileFromPath("report", path))
+	resp, err := client.Stream(context.Background(), req,
+		network.WithHeader("Idempotency-Key", "upload-1"),
+		network.WithRetryOnStatus(2, time.Millisecond, time.Millisecond, 1, http.StatusServiceUnavailable),
+	)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	resp.Body.Close()
+
+	if got := buf.String(); got != "finance:q1.csv:a,b\n1,2\n" {
+		t.Fatalf("Unexpected upload echo %q", got)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("Expected the upload to be retried once, got %d attempts", attempts.Load())
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/tracing"
+)
+
+// call carries a request's network.Config to the middlewares, and their
+// outcome back to the Client.
+type call struct {
+	config *network.Config
+
+	// err is returned alongside the response, such as
+	// retry.ErrRetryableStatus when retries ran out on a retryable status.
+	err error
+}
+
+// result returns the error to report alongside resp: the middlewares'
+// error, and an *HTTPError for a non-2xx status if the call asked for one.
+func (c *call) result(resp *network.Response) error {
+	if !c.config.StatusErrors || (resp.StatusCode >= 200 && resp.StatusCode <= 299) {
+		return c.err
+	}
+	herr := newHTTPError(resp)
+	if c.err == nil {
+		return herr
+	}
+	return errors.Join(herr, c.err)
+}
+
+type callKey struct{}
+
+func withCall(ctx context.Context, c *call) context.Context {
+	return context.WithValue(ctx, callKey{}, c)
+}
+
+func callFrom(ctx context.Context) *call {
+	c, _ := ctx.Value(callKey{}).(*call)
+	return c
+}
+
+// instrument is the innermost layer of every Client: it bounds each
+// attempt with the client timeout and records a span, a metric and, on
+// failure, a log record for it. The attempt ends when the response body is
+// closed.
+func (c *Client) instrument(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		ctx, cancel := req.Context(), context.CancelFunc(func() {})
+		if c.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+		req, span := c.tracer.StartHTTP(req.Clone(ctx))
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			cancel()
+			tracing.EndHTTP(span, 0, err)
+			c.metrics.ObserveRequest("http", req.Method, "error", time.Since(start))
+			c.logger.Warn("http: request failed", "method", req.Method, "url", req.URL.Redacted(), "error", err)
+			return nil, err
+		}
+
+		resp.Body = &trackedBody{
+			ReadCloser: resp.Body,
+			done: func(err error) {
+				cancel()
+				tracing.EndHTTP(span, resp.StatusCode, err)
+				c.metrics.ObserveRequest("http", req.Method, strconv.Itoa(resp.StatusCode), time.Since(start))
+			},
+		}
+		return resp, nil
+	})
+}
+
+// trackedBody calls done once, when the body is closed, with the first
+// read error other than io.EOF.
+type trackedBody struct {
+	io.ReadCloser
+	done func(err error)
+
+	once sync.Once
+	err  error
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.err) })
+	return err
+}
@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/festus/microkit/messaging"
+)
+
+func init() {
+	messaging.Register("kafka", driver{})
+}
+
+// driver opens brokers for kafka://broker1:9092,broker2:9092?group=name URLs.
+type driver struct{}
+
+func (driver) Open(ctx context.Context, u *url.URL) (messaging.Broker, error) {
+	brokers := parseBrokers(u.Host)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("kafka: no brokers in URL %q", u.Redacted())
+	}
+
+	return NewBroker(NewConnection(brokers), u.Query().Get("group")), nil
+}
+
+func parseBrokers(host string) []string {
+	var brokers []string
+	for _, b := range strings.Split(host, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
+// Broker implements messaging.Broker on top of a Kafka connection.
+// Producers are created per topic on first publish; every subscription
+// joins the broker's consumer group.
+type Broker struct {
+	conn    *Connection
+	groupID string
+	opts    []Option
+
+	mu        sync.Mutex
+	closed    bool
+	draining  bool
+	producers map[string]*Producer
+	consumers []*Consumer
+}
+
+func NewBroker(conn *Connection, groupID string, opts ...Option) *Broker {
+	return &Broker{
+		conn:      conn,
+		groupID:   groupID,
+		opts:      opts,
+		producers: make(map[string]*Producer),
+	}
+}
+
+func (b *Broker) Publish(ctx context.Context, topic string, msg messaging.Message) error {
+	p, err := b.producer(topic)
+	if err != nil {
+		return err
+	}
+	return p.PublishMessage(ctx, nil, msg)
+}
+
+func (b *Broker) Subscribe(ctx context.Context, topic string, handler messaging.HandlerFunc) error {
+	if b.groupID == "" {
+		return fmt.Errorf("%w: kafka broker needs a consumer group (?group=name)", messaging.ErrSubscriptionErr)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.draining {
+		return messaging.ErrBrokerClosed
+	}
+
+	c := NewConsumer(b.conn, topic, b.groupID, b.opts...)
+	b.consumers = append(b.consumers, c)
+	c.start(ctx, handler)
+	return nil
+}
+
+// Shutdown stops intake on every subscription and waits for in-flight
+// handlers to finish or ctx to end. Subscribe returns
+// messaging.ErrBrokerClosed from then on; publishing still works until
+// Close.
+func (b *Broker) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.draining = true
+	consumers := append([]*Consumer(nil), b.consumers...)
+	b.mu.Unlock()
+
+	errs := make([]error, len(consumers))
+	var wg sync.WaitGroup
+	for i, c := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Flush waits for publishes in progress on every producer.
+func (b *Broker) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	producers := make([]*Producer, 0, len(b.producers))
+	for _, p := range b.producers {
+		producers = append(producers, p)
+	}
+	b.mu.Unlock()
+
+	var errs []error
+	for _, p := range producers {
+		errs = append(errs, p.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	var errs []error
+	for _, c := range b.consumers {
+		errs = append(errs, c.Close())
+	}
+	for _, p := range b.producers {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Broker) producer(topic string) (*Producer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, messaging.ErrBrokerClosed
+	}
+
+	p, ok := b.producers[topic]
+	if !ok {
+		p = NewProducer(b.conn, topic, b.opts...)
+		b.producers[topic] = p
+	}
+	return p, nil
+}
@@ -2,6 +2,8 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
 
@@ -80,3 +82,30 @@ func (c *Connection) CreateTopic(ctx context.Context, topic string) error {
 
 	return controllerConn.CreateTopics(topicConfigs...)
 }
+
+// Check reports whether any broker is reachable by fetching cluster
+// metadata from it.
+func (c *Connection) Check(ctx context.Context) error {
+	var errs []error
+	for _, broker := range c.Brokers {
+		conn, err := (&kafka.Dialer{}).DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// A broker that accepted the connection may still never answer.
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return errors.New("kafka: no brokers configured")
+	}
+	return fmt.Errorf("kafka: no broker reachable: %w", errors.Join(errs...))
+}
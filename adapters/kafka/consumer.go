@@ -3,16 +3,34 @@ package kafka
 
 import (
 	"context"
-	"log"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/festus/microkit/internal/drain"
+	"github.com/festus/microkit/internal/instrument"
+	"github.com/festus/microkit/internal/logging"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/retry"
+	"github.com/festus/microkit/tracing"
 	kafka "github.com/segmentio/kafka-go"
-	"github.com/festus/microkit/internal/retry"
 )
 
 type ConsumerConfig struct {
+	// RetryConfig retries failed handlers with exponential backoff.
+	// It is ignored when RetryStrategy is set.
 	RetryConfig retry.Config
-	EnableDLQ   bool
-	DLQTopic    string
+
+	// RetryStrategy retries failed handlers with any strategy, shaped by
+	// RetryOptions (max elapsed time, per-attempt timeout, hooks).
+	RetryStrategy retry.Strategy
+	RetryOptions  []retry.Option
+
+	EnableDLQ bool
+	DLQTopic  string
 }
 
 type Consumer struct {
@@ -21,60 +39,164 @@ type Consumer struct {
 	groupID string
 	r       *kafka.Reader
 	config  ConsumerConfig
+	opts    instrument.Options
+
+	mu    sync.Mutex
+	stops []context.CancelFunc
+	wg    sync.WaitGroup
 }
 
-func NewConsumer(conn *Connection, topic, groupID string) *Consumer {
+func NewConsumer(conn *Connection, topic, groupID string, opts ...Option) *Consumer {
 	return &Consumer{
 		conn:    conn,
 		topic:   topic,
 		groupID: groupID,
 		r:       conn.Reader(topic, groupID),
+		opts:    instrument.New(opts),
 	}
 }
 
-func NewConsumerWithConfig(conn *Connection, topic, groupID string, config ConsumerConfig) *Consumer {
+func NewConsumerWithConfig(conn *Connection, topic, groupID string, config ConsumerConfig, opts ...Option) *Consumer {
 	return &Consumer{
 		conn:    conn,
 		topic:   topic,
 		groupID: groupID,
 		r:       conn.Reader(topic, groupID),
 		config:  config,
+		opts:    instrument.New(opts),
 	}
 }
 
 func (c *Consumer) Subscribe(ctx context.Context, handler func([]byte) error) {
+	c.start(ctx, func(_ context.Context, msg messaging.Message) error {
+		return handler(msg.Payload)
+	})
+}
+
+// Shutdown stops reading new messages and waits for in-flight handlers to
+// finish or ctx to end. It does not close the reader; call Close afterwards.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	for _, stop := range c.stops {
+		stop()
+	}
+	c.mu.Unlock()
+
+	return drain.Wait(ctx, &c.wg)
+}
+
+// start runs consume in a new goroutine tracked by Shutdown.
+func (c *Consumer) start(ctx context.Context, handler messaging.HandlerFunc) {
+	readCtx, stop := context.WithCancel(ctx)
+
+	c.mu.Lock()
+	c.stops = append(c.stops, stop)
+	c.mu.Unlock()
+
+	c.wg.Add(1)
 	go func() {
-		for {
-			m, err := c.r.ReadMessage(ctx)
-			if err != nil {
-				log.Println("Error reading message:", err)
-				continue
+		defer c.wg.Done()
+		c.consume(ctx, readCtx, handler)
+	}()
+}
+
+// consume reads messages until readCtx is cancelled or the reader is closed,
+// applying the configured retry and DLQ behaviour to each message. Handlers
+// run with ctx so that stopping intake does not cancel in-flight work.
+func (c *Consumer) consume(ctx, readCtx context.Context, handler messaging.HandlerFunc) {
+	for {
+		m, err := c.r.ReadMessage(readCtx)
+		if err != nil {
+			if readCtx.Err() != nil || errors.Is(err, io.EOF) {
+				return
 			}
+			c.opts.Logger.Error("kafka: read failed", "topic", c.topic, "group", c.groupID, "error", err)
+			continue
+		}
 
-			if c.config.RetryConfig.MaxAttempts > 0 {
-				err = retry.Execute(ctx, c.config.RetryConfig, func() error {
-					return handler(m.Value)
-				})
-			} else {
-				err = handler(m.Value)
+		msg := toMessage(m)
+		msgCtx, span := c.opts.Tracer.StartProcess(ctx, "kafka", c.topic, msg)
+
+		attempt := 0
+		handle := func(ctx context.Context) error {
+			attempt++
+			if attempt > 1 {
+				c.opts.Metrics.IncRetry("kafka", c.topic, attempt)
 			}
+			start := time.Now()
+			err := handler(ctx, msg)
+			c.opts.Metrics.ObserveHandle("kafka", c.topic, attempt, metrics.OutcomeOf(err), time.Since(start))
+			return err
+		}
+
+		if strategy := c.retryStrategy(); strategy != nil {
+			err = retry.Do(msgCtx, strategy, handle, c.config.RetryOptions...)
+		} else {
+			err = handle(msgCtx)
+		}
+		tracing.End(span, err)
 
-			if err != nil {
-				log.Printf("Handler error, message: %s, err: %v\n", string(m.Value), err)
-				if c.config.EnableDLQ {
-					c.sendToDLQ(ctx, m.Value)
-				}
+		if err != nil {
+			attrs := append(logging.Message(c.topic, msg, c.opts.LogPayloads),
+				"attempt", attempt, "error", err)
+			c.opts.Logger.Error("kafka: handler failed", attrs...)
+			if c.config.EnableDLQ {
+				c.opts.Metrics.IncDeadLetter("kafka", c.topic)
+				c.sendToDLQ(ctx, msg)
 			}
 		}
-	}()
+	}
 }
 
-func (c *Consumer) sendToDLQ(ctx context.Context, message []byte) {
-	producer := NewProducer(c.conn, c.config.DLQTopic)
+func (c *Consumer) retryStrategy() retry.Strategy {
+	if c.config.RetryStrategy != nil {
+		return c.config.RetryStrategy
+	}
+	if c.config.RetryConfig.MaxAttempts > 0 {
+		return c.config.RetryConfig
+	}
+	return nil
+}
+
+func (c *Consumer) sendToDLQ(ctx context.Context, msg messaging.Message) {
+	producer := NewProducer(c.conn, c.config.DLQTopic, WithTracer(c.opts.Tracer), WithMetrics(c.opts.Metrics))
 	defer producer.Close()
-	producer.Publish(ctx, nil, message)
+
+	if err := producer.Publish(ctx, nil, msg.Payload); err != nil {
+		attrs := append(logging.Message(c.config.DLQTopic, msg, c.opts.LogPayloads), "error", err)
+		c.opts.Logger.Error("kafka: dead-letter publish failed", attrs...)
+	}
 }
 
 func (c *Consumer) Close() error {
 	return c.r.Close()
-}
\ No newline at end of file
+}
+
+// toMessage converts a Kafka record into a messaging.Message. Its ID is
+// the MessageIDHeader header, or else the record key, or else the record's
+// topic, partition and offset.
+func toMessage(m kafka.Message) messaging.Message {
+	headers := make(map[string]string, len(m.Headers))
+	var id string
+	for _, h := range m.Headers {
+		if h.Key == MessageIDHeader {
+			id = string(h.Value)
+			continue
+		}
+		headers[h.Key] = string(h.Value)
+	}
+
+	if id == "" {
+		id = string(m.Key)
+	}
+	if id == "" {
+		id = m.Topic + "/" + strconv.Itoa(m.Partition) + "/" + strconv.FormatInt(m.Offset, 10)
+	}
+
+	return messaging.Message{
+		ID:        id,
+		Payload:   m.Value,
+		Headers:   headers,
+		Timestamp: m.Time.Unix(),
+	}
+}
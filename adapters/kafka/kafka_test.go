@@ -2,7 +2,14 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/festus/microkit/lifecycle"
+	"github.com/festus/microkit/messaging"
+	"github.com/segmentio/kafka-go"
 )
 
 func TestKafkaStructures(t *testing.T) {
@@ -82,3 +89,112 @@ func TestKafkaIntegration(t *testing.T) {
 		t.Logf("Kafka integration test failed as expected (no real Kafka): %v", err)
 	}
 }
+
+func TestKafkaDriver(t *testing.T) {
+	ctx := context.Background()
+
+	broker, err := messaging.Open(ctx, "kafka://broker1:9092,broker2:9092?group=orders")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer broker.Close()
+
+	kb, ok := broker.(*Broker)
+	if !ok {
+		t.Fatalf("Expected *Broker, got %T", broker)
+	}
+	if len(kb.conn.Brokers) != 2 || kb.conn.Brokers[1] != "broker2:9092" {
+		t.Fatalf("Unexpected brokers: %v", kb.conn.Brokers)
+	}
+	if kb.groupID != "orders" {
+		t.Fatalf("Expected group orders, got %s", kb.groupID)
+	}
+
+	noGroup := NewBroker(NewConnection([]string{"localhost:9092"}), "")
+	err = noGroup.Subscribe(ctx, "orders", func(ctx context.Context, msg messaging.Message) error { return nil })
+	if !errors.Is(err, messaging.ErrSubscriptionErr) {
+		t.Fatalf("Expected ErrSubscriptionErr, got %v", err)
+	}
+}
+
+func TestShutdownFlushesProducer(t *testing.T) {
+	// A broker that accepts connections and never answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+	defer func() {
+		ln.Close()
+		for len(accepted) > 0 {
+			(<-accepted).Close()
+		}
+	}()
+
+	prod := NewProducer(NewConnection([]string{ln.Addr().String()}), "orders")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go prod.Publish(ctx, []byte("key"), []byte("message"))
+	select {
+	case c := <-accepted:
+		accepted <- c
+	case <-time.After(time.Second):
+		t.Fatal("The publish never reached the broker")
+	}
+
+	g := lifecycle.NewGroup(lifecycle.WithTimeout(20 * time.Millisecond))
+	g.Add("orders-producer", prod)
+	err = g.Shutdown(context.Background())
+
+	var se *lifecycle.ShutdownError
+	if !errors.As(err, &se) || se.Components[0].Phase != lifecycle.PhaseFlush || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the unacknowledged publish to fail the flush, got %v", err)
+	}
+	if err := prod.Flush(context.Background()); err != nil {
+		t.Fatalf("Expected nothing left to flush once the publish gave up, got %v", err)
+	}
+}
+
+func TestMessageIDStaysOutOfKey(t *testing.T) {
+	// A keyless record gets a synthetic ID, which must not become the key
+	// when the message is published again.
+	in := toMessage(kafka.Message{Topic: "orders", Partition: 3, Offset: 42, Value: []byte("x")})
+	if in.ID != "orders/3/42" {
+		t.Fatalf("Expected a synthetic ID, got %q", in.ID)
+	}
+	out := fromMessage(nil, in)
+	if out.Key != nil {
+		t.Fatalf("Expected no record key, got %q", out.Key)
+	}
+	if again := toMessage(out); again.ID != in.ID || len(again.Headers) != 0 {
+		t.Fatalf("Expected the ID to travel in a header, got %+v", again)
+	}
+
+	keyed := toMessage(fromMessage([]byte("customer-1"), messaging.Message{Payload: []byte("x")}))
+	if keyed.ID != "customer-1" {
+		t.Fatalf("Expected keyed records without an ID header to use the key, got %q", keyed.ID)
+	}
+}
+
+func TestSubscribeAfterShutdown(t *testing.T) {
+	ctx := context.Background()
+	broker := NewBroker(NewConnection([]string{"localhost:9092"}), "orders")
+	defer broker.Close()
+
+	if err := broker.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	err := broker.Subscribe(ctx, "orders", func(ctx context.Context, msg messaging.Message) error { return nil })
+	if !errors.Is(err, messaging.ErrBrokerClosed) {
+		t.Fatalf("Expected ErrBrokerClosed, got %v", err)
+	}
+}
@@ -4,33 +4,83 @@ import (
 	"context"
 	"time"
 
+	"github.com/festus/microkit/internal/drain"
+	"github.com/festus/microkit/internal/instrument"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/tracing"
 	"github.com/segmentio/kafka-go"
 )
 
+// MessageIDHeader is the Kafka header that carries a message's ID.
+const MessageIDHeader = "message-id"
+
 type Producer struct {
-	conn  *Connection
-	topic string
-	W     *kafka.Writer
+	conn    *Connection
+	topic   string
+	W       *kafka.Writer
+	opts    instrument.Options
+	pending drain.Pending
 }
 
-func NewProducer(conn *Connection, topic string) *Producer {
+func NewProducer(conn *Connection, topic string, opts ...Option) *Producer {
 	return &Producer{
 		conn:  conn,
 		topic: topic,
 		W:     conn.Writer(topic),
+		opts:  instrument.New(opts),
 	}
 }
 
 func (p *Producer) Publish(ctx context.Context, key []byte, message []byte) error {
-	msg := kafka.Message{
-		Key:   key,
-		Value: message,
-		Time:  time.Now(),
-	}
+	return p.PublishMessage(ctx, key, messaging.Message{Payload: message})
+}
+
+// PublishMessage publishes a messaging.Message as a record with key, which
+// may be nil. Its ID is carried in the MessageIDHeader header and its
+// headers as Kafka headers; the ID never becomes the key, so consumed
+// messages can be published again without changing their partitioning.
+func (p *Producer) PublishMessage(ctx context.Context, key []byte, msg messaging.Message) error {
+	p.pending.Add()
+	defer p.pending.Done()
 
-	return p.W.WriteMessages(ctx, msg)
+	start := time.Now()
+	ctx, span := p.opts.Tracer.StartPublish(ctx, "kafka", p.topic, &msg)
+	err := p.W.WriteMessages(ctx, fromMessage(key, msg))
+	tracing.End(span, err)
+	p.opts.Metrics.ObservePublish("kafka", p.topic, metrics.OutcomeOf(err), time.Since(start))
+	return err
+}
+
+// Flush waits for publishes in progress to be acknowledged by the brokers
+// or ctx to end.
+func (p *Producer) Flush(ctx context.Context) error {
+	return p.pending.Wait(ctx)
 }
 
 func (p *Producer) Close() error {
 	return p.W.Close()
 }
+
+// fromMessage converts a messaging.Message into a Kafka record.
+func fromMessage(key []byte, msg messaging.Message) kafka.Message {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	if msg.ID != "" {
+		headers = append(headers, kafka.Header{Key: MessageIDHeader, Value: []byte(msg.ID)})
+	}
+
+	ts := time.Now()
+	if msg.Timestamp > 0 {
+		ts = time.Unix(msg.Timestamp, 0)
+	}
+
+	return kafka.Message{
+		Key:     key,
+		Value:   msg.Payload,
+		Headers: headers,
+		Time:    ts,
+	}
+}
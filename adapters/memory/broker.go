@@ -0,0 +1,177 @@
+// Package memory provides an in-process message broker for tests and
+// local development. It registers the "mem" scheme with messaging.Open.
+package memory
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/festus/microkit/internal/drain"
+	"github.com/festus/microkit/internal/instrument"
+	"github.com/festus/microkit/internal/logging"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/tracing"
+)
+
+// bufferSize is the number of undelivered messages held per topic before
+// Publish blocks.
+const bufferSize = 1024
+
+func init() {
+	messaging.Register("mem", driver{})
+}
+
+type driver struct{}
+
+func (driver) Open(ctx context.Context, u *url.URL) (messaging.Broker, error) {
+	return NewBroker(messaging.DefaultConfig()), nil
+}
+
+// Broker is an in-memory messaging.Broker. Each topic is a queue: messages
+// are delivered to exactly one of the topic's subscribers. Failed handlers
+// are retried up to Config.RetryCount times before the message is dropped.
+type Broker struct {
+	config messaging.Config
+	opts   instrument.Options
+	seq    atomic.Uint64
+
+	mu     sync.Mutex
+	topics map[string]chan messaging.Message
+	done   chan struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewBroker(cfg messaging.Config, opts ...Option) *Broker {
+	return &Broker{
+		config: cfg,
+		opts:   instrument.New(opts),
+		topics: make(map[string]chan messaging.Message),
+		done:   make(chan struct{}),
+	}
+}
+
+func (b *Broker) Publish(ctx context.Context, topic string, msg messaging.Message) error {
+	queue, err := b.queue(topic)
+	if err != nil {
+		return err
+	}
+
+	if msg.ID == "" {
+		msg.ID = "mem-" + strconv.FormatUint(b.seq.Add(1), 10)
+	}
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().Unix()
+	}
+
+	start := time.Now()
+	ctx, span := b.opts.Tracer.StartPublish(ctx, "memory", topic, &msg)
+	select {
+	case queue <- msg:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-b.done:
+		err = messaging.ErrBrokerClosed
+	}
+	tracing.End(span, err)
+	b.opts.Metrics.ObservePublish("memory", topic, metrics.OutcomeOf(err), time.Since(start))
+	return err
+}
+
+func (b *Broker) Subscribe(ctx context.Context, topic string, handler messaging.HandlerFunc) error {
+	b.mu.Lock()
+	queue, err := b.queueLocked(topic)
+	if err == nil {
+		b.wg.Add(1)
+	}
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer b.wg.Done()
+		for {
+			select {
+			case msg := <-queue:
+				b.deliver(ctx, topic, msg, handler)
+			case <-ctx.Done():
+				return
+			case <-b.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops all subscriptions and waits for in-flight handlers to
+// return or ctx to end. Messages still queued are discarded.
+func (b *Broker) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	b.mu.Unlock()
+
+	return drain.Wait(ctx, &b.wg)
+}
+
+// Close stops all subscriptions and waits for in-flight handlers to return.
+func (b *Broker) Close() error {
+	return b.Shutdown(context.Background())
+}
+
+func (b *Broker) deliver(ctx context.Context, topic string, msg messaging.Message, handler messaging.HandlerFunc) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		msgCtx, span := b.opts.Tracer.StartProcess(ctx, "memory", topic, msg)
+		err := handler(msgCtx, msg)
+		tracing.End(span, err)
+		b.opts.Metrics.ObserveHandle("memory", topic, attempt, metrics.OutcomeOf(err), time.Since(start))
+		if err == nil {
+			return
+		}
+		if attempt > b.config.RetryCount {
+			attrs := append(logging.Message(topic, msg, b.opts.LogPayloads),
+				"attempt", attempt, "error", err)
+			b.opts.Logger.Warn("memory: dropping message after retries", attrs...)
+			b.opts.Metrics.IncDeadLetter("memory", topic)
+			return
+		}
+		b.opts.Metrics.IncRetry("memory", topic, attempt+1)
+
+		select {
+		case <-time.After(b.config.RetryDelay):
+		case <-ctx.Done():
+			return
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *Broker) queue(topic string) (chan messaging.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queueLocked(topic)
+}
+
+func (b *Broker) queueLocked(topic string) (chan messaging.Message, error) {
+	if b.closed {
+		return nil, messaging.ErrBrokerClosed
+	}
+
+	q, ok := b.topics[topic]
+	if !ok {
+		q = make(chan messaging.Message, bufferSize)
+		b.topics[topic] = q
+	}
+	return q, nil
+}
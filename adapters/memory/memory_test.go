@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/messaging"
+)
+
+func TestOpenPublishSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker, err := messaging.Open(ctx, "mem://")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer broker.Close()
+
+	received := make(chan messaging.Message, 1)
+	err = broker.Subscribe(ctx, "orders", func(ctx context.Context, msg messaging.Message) error {
+		received <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	err = broker.Publish(ctx, "orders", messaging.Message{
+		Payload: []byte("hello"),
+		Headers: map[string]string{"k": "v"},
+	})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if string(msg.Payload) != "hello" {
+			t.Fatalf("Expected 'hello', got '%s'", msg.Payload)
+		}
+		if msg.Headers["k"] != "v" {
+			t.Fatalf("Expected header k=v, got %v", msg.Headers)
+		}
+		if msg.ID == "" {
+			t.Fatal("Expected message ID to be assigned")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+}
+
+func TestRetryThenDrop(t *testing.T) {
+	ctx := context.Background()
+	broker := NewBroker(messaging.Config{RetryCount: 2, RetryDelay: time.Millisecond})
+
+	attempts := make(chan struct{}, 10)
+	broker.Subscribe(ctx, "jobs", func(ctx context.Context, msg messaging.Message) error {
+		attempts <- struct{}{}
+		return errors.New("boom")
+	})
+	broker.Publish(ctx, "jobs", messaging.Message{Payload: []byte("x")})
+
+	time.Sleep(100 * time.Millisecond)
+	broker.Close()
+
+	if got := len(attempts); got != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", got)
+	}
+}
+
+func TestUnknownDriver(t *testing.T) {
+	_, err := messaging.Open(context.Background(), "nats://localhost")
+	if !errors.Is(err, messaging.ErrUnknownDriver) {
+		t.Fatalf("Expected ErrUnknownDriver, got %v", err)
+	}
+}
+
+func TestLoggingRedactsPayload(t *testing.T) {
+	for _, withPayload := range []bool{false, true} {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+		broker := NewBroker(messaging.Config{}, WithLogger(logger), WithPayloadLogging(withPayload))
+
+		ctx := context.Background()
+		broker.Subscribe(ctx, "users", func(ctx context.Context, msg messaging.Message) error {
+			return errors.New("boom")
+		})
+		broker.Publish(ctx, "users", messaging.Message{ID: "42", Payload: []byte("secret-email@example.com")})
+
+		time.Sleep(50 * time.Millisecond)
+		broker.Close()
+
+		out := buf.String()
+		if !strings.Contains(out, `"message_id":"42"`) || !strings.Contains(out, `"topic":"users"`) {
+			t.Fatalf("Expected structured message fields, got %s", out)
+		}
+		if got := strings.Contains(out, "secret-email"); got != withPayload {
+			t.Fatalf("Payload logged = %v, want %v: %s", got, withPayload, out)
+		}
+	}
+}
+
+func TestNilOptionsKeepDefaults(t *testing.T) {
+	broker := NewBroker(messaging.Config{RetryCount: 1, RetryDelay: time.Millisecond},
+		WithTracer(nil), WithMetrics(nil), WithLogger(nil))
+
+	ctx := context.Background()
+	attempts := make(chan struct{}, 10)
+	broker.Subscribe(ctx, "jobs", func(ctx context.Context, msg messaging.Message) error {
+		attempts <- struct{}{}
+		return errors.New("boom")
+	})
+	if err := broker.Publish(ctx, "jobs", messaging.Message{Payload: []byte("x")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	broker.Close()
+
+	if got := len(attempts); got != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", got)
+	}
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"log/slog"
+
+	"github.com/festus/microkit/internal/instrument"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/tracing"
+)
+
+// Option configures a Broker.
+type Option func(*instrument.Options)
+
+// WithTracer sets the tracer used to create spans and propagate trace
+// context through message headers. A nil tracer keeps the default.
+func WithTracer(t *tracing.Tracer) Option {
+	return func(o *instrument.Options) {
+		o.SetTracer(t)
+	}
+}
+
+// WithMetrics sets the recorder for publish, handler, retry and
+// dead-letter measurements. A nil recorder keeps the default, which
+// records nothing.
+func WithMetrics(r metrics.Recorder) Option {
+	return func(o *instrument.Options) {
+		o.SetMetrics(r)
+	}
+}
+
+// WithLogger sets the logger for structured events. Defaults to a logger
+// that discards everything, which a nil logger keeps.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *instrument.Options) {
+		o.SetLogger(l)
+	}
+}
+
+// WithPayloadLogging includes message payloads in log records. Payloads
+// are redacted by default because they may carry personal data.
+func WithPayloadLogging(enabled bool) Option {
+	return func(o *instrument.Options) {
+		o.LogPayloads = enabled
+	}
+}
@@ -0,0 +1,85 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/festus/microkit/messaging"
+)
+
+func init() {
+	messaging.Register("amqp", driver{})
+	messaging.Register("amqps", driver{})
+}
+
+// driver opens brokers for amqp:// and amqps:// URLs.
+type driver struct{}
+
+func (driver) Open(ctx context.Context, u *url.URL) (messaging.Broker, error) {
+	conn, err := NewConnection(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := NewBroker(conn, messaging.DefaultConfig())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Broker implements messaging.Broker with a producer and a consumer
+// sharing one RabbitMQ connection. Closing the broker closes the connection.
+type Broker struct {
+	conn     *Connection
+	producer *Producer
+	consumer *Consumer
+}
+
+func NewBroker(conn *Connection, cfg messaging.Config, opts ...Option) (*Broker, error) {
+	producer, err := NewProducer(conn, cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := NewConsumer(conn, cfg, opts...)
+	if err != nil {
+		producer.Close()
+		return nil, err
+	}
+
+	return &Broker{
+		conn:     conn,
+		producer: producer,
+		consumer: consumer,
+	}, nil
+}
+
+func (b *Broker) Publish(ctx context.Context, topic string, msg messaging.Message) error {
+	return b.producer.Publish(ctx, topic, msg)
+}
+
+func (b *Broker) Subscribe(ctx context.Context, topic string, handler messaging.HandlerFunc) error {
+	return b.consumer.Subscribe(ctx, topic, handler)
+}
+
+// Shutdown stops intake and waits for in-flight handlers to finish or ctx
+// to end.
+func (b *Broker) Shutdown(ctx context.Context) error {
+	return b.consumer.Shutdown(ctx)
+}
+
+// Flush waits for the broker to confirm every published message.
+func (b *Broker) Flush(ctx context.Context) error {
+	return b.producer.Flush(ctx)
+}
+
+func (b *Broker) Close() error {
+	return errors.Join(
+		b.consumer.Close(),
+		b.producer.Close(),
+		b.conn.Close(),
+	)
+}
@@ -1,19 +1,23 @@
 package rabbitmq
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/festus/microkit/internal/instrument"
 	"github.com/rabbitmq/amqp091-go"
 )
 
 type Connection struct {
 	URL  string
 	conn *amqp091.Connection
+	opts instrument.Options
 }
 
-func NewConnection(url string) (*Connection, error) {
-	c := &Connection{URL: url}
+func NewConnection(url string, opts ...Option) (*Connection, error) {
+	c := &Connection{URL: url, opts: instrument.New(opts)}
 
 	err := c.connect()
 	if err != nil {
@@ -27,19 +31,19 @@ func (c *Connection) connect() error {
 	var err error
 
 	// Retry connection up to 3 times
-	for range 3 {
+	for attempt := 1; attempt <= 3; attempt++ {
 		c.conn, err = amqp091.Dial(c.URL)
 		if err == nil {
 			return nil
 		}
-		log.Println("RabbitMQ connection failed, retrying in 1s...")
+		c.opts.Logger.Warn("rabbitmq: connection failed, retrying in 1s", "attempt", attempt, "error", err)
 		time.Sleep(1 * time.Second)
 	}
 	return err
 }
 
 func (c *Connection) Close() error {
-	if c.conn != nil {
+	if c.conn != nil && !c.conn.IsClosed() {
 		return c.conn.Close()
 	}
 	return nil
@@ -48,3 +52,33 @@ func (c *Connection) Close() error {
 func (c *Connection) GetConnection() *amqp091.Connection {
 	return c.conn
 }
+
+// Check reports whether the connection is open and can open a channel.
+func (c *Connection) Check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.conn == nil || c.conn.IsClosed() {
+		return errors.New("rabbitmq: connection closed")
+	}
+
+	// Opening a channel waits for the server, which may never answer on a
+	// half-open connection, so give up when ctx ends.
+	done := make(chan error, 1)
+	go func() {
+		ch, err := c.conn.Channel()
+		if err == nil {
+			err = ch.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("rabbitmq: open channel: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,9 +2,17 @@ package rabbitmq
 
 import (
 	"context"
-	"log"
-
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/festus/microkit/internal/drain"
+	"github.com/festus/microkit/internal/instrument"
+	"github.com/festus/microkit/internal/logging"
 	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/tracing"
 	"github.com/rabbitmq/amqp091-go"
 )
 
@@ -17,9 +25,14 @@ type Consumer struct {
 	conn   *Connection
 	ch     *amqp091.Channel
 	config messaging.Config
+	opts   instrument.Options
+
+	mu   sync.Mutex
+	tags []string
+	wg   sync.WaitGroup
 }
 
-func NewConsumer(conn *Connection, cfg messaging.Config) (*Consumer, error) {
+func NewConsumer(conn *Connection, cfg messaging.Config, opts ...Option) (*Consumer, error) {
 	ch, err := conn.GetConnection().Channel()
 	if err != nil {
 		return nil, err
@@ -29,6 +42,7 @@ func NewConsumer(conn *Connection, cfg messaging.Config) (*Consumer, error) {
 		conn:   conn,
 		ch:     ch,
 		config: cfg,
+		opts:   instrument.New(opts),
 	}, nil
 }
 
@@ -57,7 +71,12 @@ func (c *Consumer) Subscribe(
 		return err
 	}
 
-	msgs, err := c.consume(queue)
+	c.mu.Lock()
+	tag := fmt.Sprintf("microkit.%s.%d", queue.Name, len(c.tags))
+	c.tags = append(c.tags, tag)
+	c.mu.Unlock()
+
+	msgs, err := c.consume(queue, tag)
 	if err != nil {
 		return err
 	}
@@ -66,6 +85,24 @@ func (c *Consumer) Subscribe(
 	return nil
 }
 
+// Shutdown cancels every subscription so the broker stops delivering, then
+// waits for deliveries already received to be handled or ctx to end.
+// It does not close the channel; call Close afterwards.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	tags := append([]string(nil), c.tags...)
+	c.mu.Unlock()
+
+	var errs []error
+	for _, tag := range tags {
+		if err := c.ch.Cancel(tag, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, drain.Wait(ctx, &c.wg))
+	return errors.Join(errs...)
+}
+
 func (c *Consumer) Close() error {
 	if c.ch != nil {
 		return c.ch.Close()
@@ -158,10 +195,10 @@ func (c *Consumer) setupMainQueue(topic, dlx string) (amqp091.Queue, error) {
 	return queue, nil
 }
 
-func (c *Consumer) consume(queue amqp091.Queue) (<-chan amqp091.Delivery, error) {
+func (c *Consumer) consume(queue amqp091.Queue, tag string) (<-chan amqp091.Delivery, error) {
 	return c.ch.Consume(
 		queue.Name,
-		"",
+		tag,
 		false, // manual ack
 		false,
 		false,
@@ -178,21 +215,29 @@ func (c *Consumer) handleMessages(
 	topic string,
 	handler messaging.HandlerFunc,
 ) {
+	c.wg.Add(1)
 	go func() {
+		defer c.wg.Done()
 		for d := range msgs {
 			retries := getRetryCount(d.Headers)
 
-			msg := messaging.Message{
-				Payload: d.Body,
-				Headers: map[string]string{},
-			}
+			msg := toMessage(d)
+			start := time.Now()
+			msgCtx, span := c.opts.Tracer.StartProcess(ctx, "rabbitmq", topic, msg)
+			err := handler(msgCtx, msg)
+			tracing.End(span, err)
+			c.opts.Metrics.ObserveHandle("rabbitmq", topic, retries+1, metrics.OutcomeOf(err), time.Since(start))
 
-			if err := handler(ctx, msg); err != nil {
+			if err != nil {
 				if retries >= maxRetries {
-					log.Println("Max retries exceeded, sending to DLQ:", err)
+					attrs := append(logging.Message(topic, msg, c.opts.LogPayloads),
+						"attempt", retries+1, "error", err)
+					c.opts.Logger.Error("rabbitmq: max retries exceeded, sending to DLQ", attrs...)
+					c.opts.Metrics.IncDeadLetter("rabbitmq", topic)
 					d.Nack(false, false)
 					continue
 				}
+				c.opts.Metrics.IncRetry("rabbitmq", topic, retries+2)
 
 				headers := d.Headers
 				if headers == nil {
@@ -200,16 +245,24 @@ func (c *Consumer) handleMessages(
 				}
 				headers["x-retry-count"] = retries + 1
 
-				_ = c.ch.Publish(
+				err = c.ch.Publish(
 					"",
 					retryName(topic),
 					false,
 					false,
 					amqp091.Publishing{
-						Headers: headers,
-						Body:    d.Body,
+						ContentType: d.ContentType,
+						MessageId:   d.MessageId,
+						Timestamp:   d.Timestamp,
+						Headers:     headers,
+						Body:        d.Body,
 					},
 				)
+				if err != nil {
+					attrs := append(logging.Message(topic, msg, c.opts.LogPayloads),
+						"attempt", retries+1, "error", err)
+					c.opts.Logger.Error("rabbitmq: retry publish failed", attrs...)
+				}
 
 				d.Ack(false)
 			} else {
@@ -221,6 +274,29 @@ func (c *Consumer) handleMessages(
 
 // Helpers
 
+// toMessage converts a delivery into a messaging.Message. Only string
+// headers are carried over.
+func toMessage(d amqp091.Delivery) messaging.Message {
+	headers := make(map[string]string, len(d.Headers))
+	for k, v := range d.Headers {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+
+	var ts int64
+	if !d.Timestamp.IsZero() {
+		ts = d.Timestamp.Unix()
+	}
+
+	return messaging.Message{
+		ID:        d.MessageId,
+		Payload:   d.Body,
+		Headers:   headers,
+		Timestamp: ts,
+	}
+}
+
 func getRetryCount(headers amqp091.Table) int {
 	if headers == nil {
 		return 0
@@ -2,32 +2,68 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/festus/microkit/internal/instrument"
 	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/tracing"
 	"github.com/rabbitmq/amqp091-go"
 )
 
+// ErrNotConfirmed is returned by Flush when the broker rejected
+// published messages.
+var ErrNotConfirmed = errors.New("rabbitmq: publish not confirmed")
+
 type Producer struct {
 	conn   *Connection
 	ch     *amqp091.Channel
 	config messaging.Config
+	opts   instrument.Options
+
+	mu          sync.Mutex
+	unconfirmed []*amqp091.DeferredConfirmation
+	nacked      int
 }
 
-func NewProducer(conn *Connection, cfg messaging.Config) (*Producer, error) {
+func NewProducer(conn *Connection, cfg messaging.Config, opts ...Option) (*Producer, error) {
 	ch, err := conn.GetConnection().Channel()
 	if err != nil {
 		return nil, err
 	}
+	// Publisher confirms let Flush wait until the broker has taken
+	// responsibility for every message.
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
 
 	return &Producer{
 		conn:   conn,
 		ch:     ch,
 		config: cfg,
+		opts:   instrument.New(opts),
 	}, nil
 }
 
 func (p *Producer) Publish(ctx context.Context, topic string, msg messaging.Message) error {
-	return p.ch.PublishWithContext(
+	start := time.Now()
+	ctx, span := p.opts.Tracer.StartPublish(ctx, "rabbitmq", topic, &msg)
+
+	headers := amqp091.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	var ts time.Time
+	if msg.Timestamp > 0 {
+		ts = time.Unix(msg.Timestamp, 0)
+	}
+
+	confirm, err := p.ch.PublishWithDeferredConfirmWithContext(
 		ctx,
 		"amq.topic", // exchange
 		topic,
@@ -35,9 +71,67 @@ func (p *Producer) Publish(ctx context.Context, topic string, msg messaging.Mess
 		false,
 		amqp091.Publishing{
 			ContentType: "application/json",
+			MessageId:   msg.ID,
+			Timestamp:   ts,
+			Headers:     headers,
 			Body:        msg.Payload,
 		},
 	)
+	if err == nil {
+		p.track(confirm)
+	}
+	tracing.End(span, err)
+	p.opts.Metrics.ObservePublish("rabbitmq", topic, metrics.OutcomeOf(err), time.Since(start))
+	return err
+}
+
+// track remembers confirm for Flush, forgetting confirmations that have
+// already arrived.
+func (p *Producer) track(confirm *amqp091.DeferredConfirmation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending := p.unconfirmed[:0]
+	for _, c := range p.unconfirmed {
+		select {
+		case <-c.Done():
+			if !c.Acked() {
+				p.nacked++
+			}
+		default:
+			pending = append(pending, c)
+		}
+	}
+	p.unconfirmed = append(pending, confirm)
+}
+
+// Flush waits for the broker to confirm every message published so far,
+// or ctx to end. It returns ErrNotConfirmed if any were rejected since
+// the last Flush.
+func (p *Producer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	pending, nacked := p.unconfirmed, p.nacked
+	p.unconfirmed, p.nacked = nil, 0
+	p.mu.Unlock()
+
+	for i, c := range pending {
+		acked, err := c.WaitContext(ctx)
+		if err != nil {
+			// Leave the rest for the next Flush.
+			p.mu.Lock()
+			p.unconfirmed = append(pending[i:], p.unconfirmed...)
+			p.nacked += nacked
+			p.mu.Unlock()
+			return err
+		}
+		if !acked {
+			nacked++
+		}
+	}
+	if nacked > 0 {
+		return fmt.Errorf("%w: %d messages rejected", ErrNotConfirmed, nacked)
+	}
+	return nil
 }
 
 func (p *Producer) Close() error {
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/festus/microkit/lifecycle"
 	"github.com/festus/microkit/messaging"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -209,3 +210,30 @@ func TestRetryDLQ(t *testing.T) {
 		}
 	}
 }
+
+// -------------------------
+// Test: Flush through a lifecycle.Group
+// -------------------------
+func TestShutdownFlushesConfirms(t *testing.T) {
+	conn := initConnection(t)
+
+	producer, err := NewProducer(conn, messaging.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := producer.Publish(ctx, "flush-topic", messaging.Message{Payload: []byte("flush test")}); err != nil {
+			t.Fatalf("Failed to publish message: %v", err)
+		}
+	}
+
+	g := lifecycle.NewGroup(lifecycle.WithTimeout(5 * time.Second))
+	g.Add("rabbitmq", conn)
+	g.Add("producer", producer)
+	if err := g.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if len(producer.unconfirmed) != 0 {
+		t.Fatalf("Expected every publish to be confirmed, %d left", len(producer.unconfirmed))
+	}
+}
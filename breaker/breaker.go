@@ -0,0 +1,348 @@
+// Package breaker implements a circuit breaker with closed, open and
+// half-open states.
+//
+// A closed breaker lets calls through and trips open after too many
+// consecutive failures or too high a failure rate over a rolling window.
+// An open breaker rejects calls with ErrCircuitOpen until OpenTimeout has
+// passed, then lets a limited number of half-open probes through: if they
+// all succeed the breaker closes, and any failure opens it again.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a call is rejected without being made.
+var ErrCircuitOpen = errors.New("breaker: circuit open")
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Config configures when a Breaker trips and recovers. Zero values use the
+// documented defaults.
+type Config struct {
+	// ConsecutiveFailures trips the breaker after this many failures in a
+	// row. Zero disables the check. Defaults to 5 when FailureRate is
+	// also zero.
+	ConsecutiveFailures int
+
+	// FailureRate trips the breaker when the fraction of failed calls in
+	// the rolling window reaches it, once MinRequests calls were seen.
+	// Zero disables the check.
+	FailureRate float64
+	MinRequests int
+
+	// Window is the length of the rolling window, split into Buckets.
+	// Defaults to 10s and 10 buckets.
+	Window  time.Duration
+	Buckets int
+
+	// OpenTimeout is how long the breaker stays open before probing.
+	// Defaults to 30s.
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is how many concurrent probes are let through while
+	// half-open, and how many must succeed to close. Defaults to 1.
+	HalfOpenProbes int
+
+	// IsFailure reports whether err counts as a failure. Nil counts every
+	// non-nil error except context cancellation.
+	IsFailure func(err error) bool
+
+	// OnStateChange is called after every transition.
+	OnStateChange func(name string, from, to State)
+}
+
+func (c Config) withDefaults() Config {
+	if c.ConsecutiveFailures == 0 && c.FailureRate == 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 10
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = 1
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		}
+	}
+	return c
+}
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// Breaker is a single circuit breaker. It is safe for concurrent use.
+type Breaker struct {
+	name string
+	cfg  Config
+	now  func() time.Time
+
+	mu          sync.Mutex
+	state       State
+	generation  uint64
+	openedAt    time.Time
+	consecutive int
+	buckets     []bucket
+	probes      int
+	probeOK     int
+}
+
+func New(name string, cfg Config) *Breaker {
+	cfg = cfg.withDefaults()
+	return &Breaker{
+		name:    name,
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: make([]bucket, cfg.Buckets),
+	}
+}
+
+// Name returns the name the breaker was created with.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, moving from open to half-open if the
+// open timeout has passed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	change := b.advance()
+	state := b.state
+	b.mu.Unlock()
+
+	b.notify(change)
+	return state
+}
+
+// Allow reports whether a call may proceed. If it may, the caller must
+// invoke done with the call's result; otherwise err is ErrCircuitOpen.
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	change := b.advance()
+
+	switch b.state {
+	case Open:
+		b.mu.Unlock()
+		b.notify(change)
+		return nil, ErrCircuitOpen
+	case HalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			b.mu.Unlock()
+			b.notify(change)
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(change)
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(generation, b.cfg.IsFailure(err)) })
+	}, nil
+}
+
+// Execute runs fn if the breaker allows it and records the result.
+func (b *Breaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn(ctx)
+	done(err)
+	return err
+}
+
+// RetryIn returns how long until the breaker may admit a call again. It is
+// zero when the breaker is closed.
+func (b *Breaker) RetryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if d := b.openedAt.Add(b.cfg.OpenTimeout).Sub(b.now()); d > 0 {
+			return d
+		}
+	case HalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			// Wait for the in-flight probes to settle.
+			return b.cfg.OpenTimeout / 10
+		}
+	}
+	return 0
+}
+
+type transition struct {
+	from, to State
+}
+
+func (b *Breaker) notify(t *transition) {
+	if t != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.name, t.from, t.to)
+	}
+}
+
+// advance moves an open breaker to half-open once its timeout has passed.
+// b.mu must be held.
+func (b *Breaker) advance() *transition {
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		return b.setState(HalfOpen)
+	}
+	return nil
+}
+
+// setState changes state and resets the counters for it. b.mu must be held.
+func (b *Breaker) setState(to State) *transition {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.consecutive = 0
+	b.probes = 0
+	b.probeOK = 0
+
+	switch to {
+	case Open:
+		b.openedAt = b.now()
+	case Closed:
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+	return &transition{from: from, to: to}
+}
+
+// record counts the result of a call admitted in generation. Results of
+// calls admitted before the last transition are ignored.
+func (b *Breaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	var change *transition
+
+	switch b.state {
+	case HalfOpen:
+		b.probes--
+		if failed {
+			change = b.setState(Open)
+		} else if b.probeOK++; b.probeOK >= b.cfg.HalfOpenProbes {
+			change = b.setState(Closed)
+		}
+	case Closed:
+		bk := b.currentBucket()
+		if failed {
+			bk.failures++
+			b.consecutive++
+		} else {
+			bk.successes++
+			b.consecutive = 0
+		}
+		if failed && b.shouldTrip() {
+			change = b.setState(Open)
+		}
+	}
+
+	b.mu.Unlock()
+	b.notify(change)
+}
+
+func (b *Breaker) shouldTrip() bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutive >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+	if b.cfg.FailureRate <= 0 {
+		return false
+	}
+
+	var total, failures int
+	cutoff := b.now().Add(-b.cfg.Window)
+	for _, bk := range b.buckets {
+		if bk.start.After(cutoff) {
+			total += bk.successes + bk.failures
+			failures += bk.failures
+		}
+	}
+	return total > 0 && total >= b.cfg.MinRequests &&
+		float64(failures)/float64(total) >= b.cfg.FailureRate
+}
+
+// currentBucket returns the bucket for now, resetting it if it has
+// rotated out of the window. b.mu must be held.
+func (b *Breaker) currentBucket() *bucket {
+	width := b.cfg.Window / time.Duration(len(b.buckets))
+	if width <= 0 {
+		width = 1
+	}
+	now := b.now()
+	start := now.Truncate(width)
+	bk := &b.buckets[int(start.UnixNano()/int64(width))%len(b.buckets)]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// Set holds one Breaker per key, such as a host or gRPC method, all
+// sharing the same Config.
+type Set struct {
+	cfg Config
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+func NewSet(cfg Config) *Set {
+	return &Set{
+		cfg:      cfg,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get returns the breaker for key, creating it on first use.
+func (s *Set) Get(key string) *Breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.breakers[key]
+	if !ok {
+		b = New(key, s.cfg)
+		s.breakers[key] = b
+	}
+	return b
+}
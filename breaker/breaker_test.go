@@ -0,0 +1,154 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/messaging"
+)
+
+var errDown = errors.New("downstream down")
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(cfg Config) (*Breaker, *clock) {
+	clk := &clock{t: time.Unix(1000, 0)}
+	b := New("test", cfg)
+	b.now = clk.now
+	return b, clk
+}
+
+func fail(b *Breaker) error {
+	return b.Execute(context.Background(), func(ctx context.Context) error { return errDown })
+}
+
+func succeed(b *Breaker) error {
+	return b.Execute(context.Background(), func(ctx context.Context) error { return nil })
+}
+
+func TestConsecutiveFailuresTripAndRecover(t *testing.T) {
+	var transitions []string
+	b, clk := newTestBreaker(Config{
+		ConsecutiveFailures: 3,
+		OpenTimeout:         time.Second,
+		OnStateChange: func(name string, from, to State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+
+	fail(b)
+	fail(b)
+	succeed(b) // resets the streak
+	fail(b)
+	fail(b)
+	if b.State() != Closed {
+		t.Fatal("Breaker should still be closed")
+	}
+	fail(b)
+	if b.State() != Open {
+		t.Fatal("Breaker should be open after 3 consecutive failures")
+	}
+	if err := succeed(b); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	clk.advance(time.Second)
+	if err := succeed(b); err != nil {
+		t.Fatalf("Expected half-open probe to be allowed, got %v", err)
+	}
+	if b.State() != Closed {
+		t.Fatal("Successful probe should close the breaker")
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("Expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("Expected transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+func TestFailureRateOverWindow(t *testing.T) {
+	b, clk := newTestBreaker(Config{FailureRate: 0.5, MinRequests: 4, Window: 10 * time.Second})
+
+	succeed(b)
+	succeed(b)
+	fail(b)
+	if b.State() != Closed {
+		t.Fatal("Should not trip below MinRequests")
+	}
+
+	// Old results roll out of the window.
+	clk.advance(11 * time.Second)
+	succeed(b)
+	fail(b)
+	fail(b)
+	if b.State() != Closed {
+		t.Fatal("Should not trip below MinRequests within the window")
+	}
+	fail(b)
+	if b.State() != Open {
+		t.Fatal("Should trip at 75% failures over 4 requests")
+	}
+}
+
+func TestHalfOpenProbeLimit(t *testing.T) {
+	b, clk := newTestBreaker(Config{ConsecutiveFailures: 1, OpenTimeout: time.Second, HalfOpenProbes: 2})
+	fail(b)
+	clk.advance(time.Second)
+
+	done1, err1 := b.Allow()
+	done2, err2 := b.Allow()
+	_, err3 := b.Allow()
+	if err1 != nil || err2 != nil || !errors.Is(err3, ErrCircuitOpen) {
+		t.Fatalf("Expected exactly 2 probes, got %v %v %v", err1, err2, err3)
+	}
+
+	done1(nil)
+	if b.State() != HalfOpen {
+		t.Fatal("One success out of two probes should keep the breaker half-open")
+	}
+	done2(errDown)
+	if b.State() != Open {
+		t.Fatal("A failed probe should reopen the breaker")
+	}
+}
+
+func TestWrapHandlerPausesWhileOpen(t *testing.T) {
+	b := New("handler", Config{ConsecutiveFailures: 1, OpenTimeout: 50 * time.Millisecond})
+
+	var calls atomic.Int32
+	h := WrapHandler(b, func(ctx context.Context, msg messaging.Message) error {
+		if calls.Add(1) == 1 {
+			return errDown
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	h(ctx, messaging.Message{})
+
+	start := time.Now()
+	if err := h(ctx, messaging.Message{}); err != nil {
+		t.Fatalf("Expected the paused handler to run once the breaker half-opens, got %v", err)
+	}
+	if waited := time.Since(start); waited < 40*time.Millisecond {
+		t.Fatalf("Expected handler to pause while open, waited %v", waited)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	fail(b)
+	cancel()
+	if err := h(cancelled, messaging.Message{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected ctx error while paused, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	microhttp "github.com/festus/microkit/adapters/http"
+	"github.com/festus/microkit/breaker"
+	"github.com/festus/microkit/network"
+)
+
+func TestHTTPClientCircuitBreaker(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := microhttp.NewClient(time.Second)
+	set := breaker.NewSet(breaker.Config{ConsecutiveFailures: 2, OpenTimeout: time.Minute})
+
+	for i := 0; i < 5; i++ {
+		client.Get(context.Background(), srv.URL,
+			network.WithCircuitBreaker(set),
+			network.WithRetry(3, time.Millisecond, time.Millisecond, 1),
+		)
+	}
+
+	_, err := client.Get(context.Background(), srv.URL, network.WithCircuitBreaker(set))
+	if !errors.Is(err, breaker.ErrCircuitOpen) {
+		t.Fatalf("Expected breaker.ErrCircuitOpen, got %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("Expected the server to see 2 requests before the circuit opened, got %d", got)
+	}
+}
@@ -0,0 +1,37 @@
+package breaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/festus/microkit/messaging"
+)
+
+// WrapHandler guards a messaging handler with b. While the breaker is open
+// the wrapped handler blocks until a probe is allowed instead of failing,
+// so a consumer calling it pauses consumption while the downstream
+// recovers. It returns ctx.Err() if ctx ends while waiting.
+func WrapHandler(b *Breaker, handler messaging.HandlerFunc) messaging.HandlerFunc {
+	return func(ctx context.Context, msg messaging.Message) error {
+		for {
+			done, err := b.Allow()
+			if err == nil {
+				err = handler(ctx, msg)
+				done(err)
+				return err
+			}
+
+			wait := b.RetryIn()
+			if wait <= 0 {
+				wait = 10 * time.Millisecond
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
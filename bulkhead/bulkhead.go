@@ -0,0 +1,236 @@
+// Package bulkhead isolates dependencies by capping the requests each may
+// have in flight.
+//
+// A Bulkhead runs up to MaxConcurrent requests at once and queues up to
+// MaxQueue more for at most QueueTimeout. Requests beyond that are turned
+// away with a *RejectedError, so one slow dependency cannot tie up every
+// goroutine and connection of a shared client. Give each dependency its
+// own Bulkhead, or take them by name from a Set.
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/festus/microkit/metrics"
+)
+
+// ErrRejected matches every *RejectedError with errors.Is.
+var ErrRejected = errors.New("bulkhead: rejected")
+
+// Reason tells why a request was rejected.
+type Reason string
+
+const (
+	QueueFull    Reason = "queue_full"
+	QueueTimeout Reason = "queue_timeout"
+)
+
+// RejectedError is returned when a bulkhead turns a request away.
+type RejectedError struct {
+	Name   string
+	Reason Reason
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("bulkhead: %s: %s", e.Name, e.Reason)
+}
+
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Config sizes a Bulkhead. Zero values use the documented defaults.
+type Config struct {
+	// MaxConcurrent is how many requests may run at once. Defaults to 10.
+	MaxConcurrent int
+
+	// MaxQueue is how many requests may wait for a slot. Zero rejects
+	// requests as soon as every slot is taken.
+	MaxQueue int
+
+	// QueueTimeout is how long a request waits for a slot. Zero waits
+	// until the request's context ends.
+	QueueTimeout time.Duration
+}
+
+// Option configures a Bulkhead.
+type Option func(*Bulkhead)
+
+// WithMetrics reports in-flight and queued requests and rejections to r.
+func WithMetrics(r metrics.BulkheadRecorder) Option {
+	return func(b *Bulkhead) {
+		b.metrics = r
+	}
+}
+
+// Bulkhead caps the concurrent requests to one dependency. It is safe for
+// concurrent use.
+type Bulkhead struct {
+	name    string
+	cfg     Config
+	metrics metrics.BulkheadRecorder
+	slots   chan struct{}
+
+	mu       sync.Mutex
+	inflight int
+	queued   int
+}
+
+func New(name string, cfg Config, opts ...Option) *Bulkhead {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 10
+	}
+	b := &Bulkhead{
+		name:    name,
+		cfg:     cfg,
+		metrics: metrics.Nop{},
+		slots:   make(chan struct{}, cfg.MaxConcurrent),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Name returns the name given to New.
+func (b *Bulkhead) Name() string {
+	return b.name
+}
+
+// InFlight returns the number of requests holding a slot.
+func (b *Bulkhead) InFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inflight
+}
+
+// Queued returns the number of requests waiting for a slot.
+func (b *Bulkhead) Queued() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queued
+}
+
+// Acquire takes a slot, queueing for one if all are taken. It returns a
+// *RejectedError if the queue is full or the queue timeout passes, and
+// ctx.Err() if ctx ends first. The caller must call release once done;
+// later calls do nothing.
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.admit(false), nil
+	default:
+	}
+
+	b.mu.Lock()
+	if b.queued >= b.cfg.MaxQueue {
+		b.mu.Unlock()
+		return nil, b.reject(QueueFull)
+	}
+	b.queued++
+	b.report()
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.cfg.QueueTimeout > 0 {
+		timer := time.NewTimer(b.cfg.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.admit(true), nil
+	case <-timeout:
+		b.dequeue()
+		return nil, b.reject(QueueTimeout)
+	case <-ctx.Done():
+		b.dequeue()
+		return nil, ctx.Err()
+	}
+}
+
+// Execute runs fn in a slot.
+func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+// admit counts a request that took a slot, coming from the queue if
+// queued, and returns its release function.
+func (b *Bulkhead) admit(queued bool) func() {
+	b.mu.Lock()
+	if queued {
+		b.queued--
+	}
+	b.inflight++
+	b.report()
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.inflight--
+			b.report()
+			b.mu.Unlock()
+			<-b.slots
+		})
+	}
+}
+
+func (b *Bulkhead) dequeue() {
+	b.mu.Lock()
+	b.queued--
+	b.report()
+	b.mu.Unlock()
+}
+
+func (b *Bulkhead) reject(reason Reason) error {
+	b.metrics.IncBulkheadRejected(b.name, string(reason))
+	return &RejectedError{Name: b.name, Reason: reason}
+}
+
+// report publishes the current saturation. b.mu must be held.
+func (b *Bulkhead) report() {
+	b.metrics.SetBulkhead(b.name, b.inflight, b.queued)
+}
+
+// Set holds one Bulkhead per dependency name, all sharing the same Config
+// and options.
+type Set struct {
+	cfg  Config
+	opts []Option
+
+	mu        sync.Mutex
+	bulkheads map[string]*Bulkhead
+}
+
+func NewSet(cfg Config, opts ...Option) *Set {
+	return &Set{
+		cfg:       cfg,
+		opts:      opts,
+		bulkheads: make(map[string]*Bulkhead),
+	}
+}
+
+// Get returns the bulkhead for name, creating it on first use.
+func (s *Set) Get(name string) *Bulkhead {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.bulkheads[name]
+	if !ok {
+		b = New(name, s.cfg, s.opts...)
+		s.bulkheads[name] = b
+	}
+	return b
+}
@@ -0,0 +1,138 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	peak     int
+	rejected map[string]int
+}
+
+func (r *recorder) SetBulkhead(name string, inflight, queued int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peak = max(r.peak, inflight)
+}
+
+func (r *recorder) IncBulkheadRejected(name, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rejected == nil {
+		r.rejected = make(map[string]int)
+	}
+	r.rejected[reason]++
+}
+
+func TestRejectsWhenFullWithoutQueue(t *testing.T) {
+	rec := &recorder{}
+	b := New("orders", Config{MaxConcurrent: 2}, WithMetrics(rec))
+
+	r1, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if _, err := b.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	_, err = b.Acquire(context.Background())
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != QueueFull || rejected.Name != "orders" {
+		t.Fatalf("Expected a queue_full rejection, got %v", err)
+	}
+	if !errors.Is(err, ErrRejected) {
+		t.Fatal("Expected the rejection to match ErrRejected")
+	}
+
+	r1()
+	r1()
+	if b.InFlight() != 1 {
+		t.Fatalf("Expected 1 in flight after release, got %d", b.InFlight())
+	}
+	if _, err := b.Acquire(context.Background()); err != nil {
+		t.Fatalf("Expected the released slot to be reused, got %v", err)
+	}
+	if rec.peak != 2 || rec.rejected[string(QueueFull)] != 1 {
+		t.Fatalf("Expected peak 2 and 1 rejection, got %d and %v", rec.peak, rec.rejected)
+	}
+}
+
+func TestQueueWaitsForSlot(t *testing.T) {
+	b := New("orders", Config{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second})
+
+	release, _ := b.Acquire(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+
+	next, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the queued request to get a slot, got %v", err)
+	}
+	defer next()
+	if b.Queued() != 0 || b.InFlight() != 1 {
+		t.Fatalf("Expected 1 in flight and none queued, got %d and %d", b.InFlight(), b.Queued())
+	}
+}
+
+func TestQueueTimeoutAndLimit(t *testing.T) {
+	rec := &recorder{}
+	b := New("orders", Config{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 30 * time.Millisecond}, WithMetrics(rec))
+	release, _ := b.Acquire(context.Background())
+	defer release()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := b.Acquire(context.Background())
+		errs <- err
+	}()
+	for b.Queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := b.Acquire(context.Background())
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != QueueFull {
+		t.Fatalf("Expected the full queue to reject, got %v", err)
+	}
+
+	err = <-errs
+	if !errors.As(err, &rejected) || rejected.Reason != QueueTimeout {
+		t.Fatalf("Expected a queue_timeout rejection, got %v", err)
+	}
+	if b.Queued() != 0 {
+		t.Fatalf("Expected the queue to be empty, got %d", b.Queued())
+	}
+	if rec.rejected[string(QueueTimeout)] != 1 {
+		t.Fatalf("Expected the timeout to be reported, got %v", rec.rejected)
+	}
+}
+
+func TestQueueHonorsContext(t *testing.T) {
+	b := New("orders", Config{MaxConcurrent: 1, MaxQueue: 1})
+	release, _ := b.Acquire(context.Background())
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSetSharesBulkheadsByName(t *testing.T) {
+	s := NewSet(Config{MaxConcurrent: 1})
+	if s.Get("orders") != s.Get("orders") {
+		t.Fatal("Expected the same bulkhead for the same name")
+	}
+	if s.Get("orders") == s.Get("billing") {
+		t.Fatal("Expected separate bulkheads per name")
+	}
+}
@@ -0,0 +1,183 @@
+package discovery
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Candidate is an endpoint offered to a Balancer with the number of
+// requests currently in flight to it.
+type Candidate struct {
+	Endpoint
+	Outstanding int64
+}
+
+// Balancer picks one of the candidates for a request and returns its
+// index. key is the request's hash key, or "". Candidates are never empty.
+// Implementations must be safe for concurrent use.
+type Balancer interface {
+	Pick(candidates []Candidate, key string) int
+}
+
+// RoundRobin cycles through the candidates in order.
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobin) Pick(candidates []Candidate, _ string) int {
+	return int((b.next.Add(1) - 1) % uint64(len(candidates)))
+}
+
+// LeastOutstanding picks the candidate with the fewest requests in
+// flight, breaking ties at random.
+func LeastOutstanding() Balancer {
+	return leastOutstanding{}
+}
+
+type leastOutstanding struct{}
+
+func (leastOutstanding) Pick(candidates []Candidate, _ string) int {
+	best, ties := 0, 1
+	for i := 1; i < len(candidates); i++ {
+		switch o := candidates[i].Outstanding; {
+		case o < candidates[best].Outstanding:
+			best, ties = i, 1
+		case o == candidates[best].Outstanding:
+			// Reservoir sampling keeps each tie equally likely.
+			if ties++; rand.IntN(ties) == 0 {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// PowerOfTwoChoices picks two candidates at random and takes the one with
+// fewer requests in flight. It approaches LeastOutstanding without herding
+// every client onto the same idle endpoint.
+func PowerOfTwoChoices() Balancer {
+	return powerOfTwo{}
+}
+
+type powerOfTwo struct{}
+
+func (powerOfTwo) Pick(candidates []Candidate, _ string) int {
+	n := len(candidates)
+	if n == 1 {
+		return 0
+	}
+	a := rand.IntN(n)
+	b := rand.IntN(n - 1)
+	if b >= a {
+		b++
+	}
+	if candidates[b].Outstanding < candidates[a].Outstanding {
+		return b
+	}
+	return a
+}
+
+// ConsistentHash routes requests with the same hash key to the same
+// endpoint, moving only the keys of endpoints that come or go. Each
+// endpoint gets replicas points on the ring; zero means 100. Requests
+// without a key are spread at random.
+func ConsistentHash(replicas int) Balancer {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	return &consistentHash{replicas: replicas, rings: make(map[string]*ring)}
+}
+
+type consistentHash struct {
+	replicas int
+
+	mu    sync.Mutex
+	rings map[string]*ring
+}
+
+type ring struct {
+	hashes []uint64
+	owners map[uint64]string
+}
+
+// maxRings bounds the rings cached for distinct endpoint sets.
+const maxRings = 64
+
+func (b *consistentHash) Pick(candidates []Candidate, key string) int {
+	if key == "" {
+		return rand.IntN(len(candidates))
+	}
+
+	addrs := make([]string, len(candidates))
+	for i, c := range candidates {
+		addrs[i] = c.Addr
+	}
+	r := b.ring(addrs)
+
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	owner := r.owners[r.hashes[i]]
+	for i, addr := range addrs {
+		if addr == owner {
+			return i
+		}
+	}
+	return 0
+}
+
+func (b *consistentHash) ring(addrs []string) *ring {
+	sorted := append([]string(nil), addrs...)
+	sort.Strings(sorted)
+	id := strings.Join(sorted, ",")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r, ok := b.rings[id]; ok {
+		return r
+	}
+
+	r := &ring{owners: make(map[uint64]string, len(addrs)*b.replicas)}
+	for _, addr := range sorted {
+		for i := 0; i < b.replicas; i++ {
+			h := hash(addr + "#" + strconv.Itoa(i))
+			if _, taken := r.owners[h]; !taken {
+				r.owners[h] = addr
+				r.hashes = append(r.hashes, h)
+			}
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+
+	if len(b.rings) >= maxRings {
+		clear(b.rings)
+	}
+	b.rings[id] = r
+	return r
+}
+
+// hash is FNV-1a followed by a 64-bit finalizer, which spreads the
+// near-identical replica names evenly over the ring.
+func hash(s string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	h := f.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
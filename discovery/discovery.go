@@ -0,0 +1,389 @@
+// Package discovery resolves logical service names to endpoints and
+// balances requests across them.
+//
+// A Resolver looks up the endpoints of a service: Static, DNS (A/AAAA or
+// SRV records) or File, a JSON list reloaded when it changes. A Directory
+// caches what the resolver returns, picks an endpoint per request with a
+// Balancer and temporarily ejects endpoints that fail repeatedly.
+//
+// adapters/http routes logical URLs such as http://orders/v1/... through a
+// Directory with its Discovery middleware, and adapters/grpc plugs one in
+// as a gRPC resolver and load balancer with WithDiscovery.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrUnknownService is returned by resolvers that have no entry for a
+	// service. The HTTP middleware passes such requests through unchanged.
+	ErrUnknownService = errors.New("discovery: unknown service")
+
+	// ErrNoEndpoints is returned when a service resolves to no endpoints.
+	ErrNoEndpoints = errors.New("discovery: no endpoints")
+)
+
+// Endpoint is one instance of a service.
+type Endpoint struct {
+	// Addr is the instance's host:port.
+	Addr string
+
+	// Weight is the relative weight from SRV records, or zero.
+	Weight int
+}
+
+// Resolver looks up the endpoints of a logical service name.
+type Resolver interface {
+	Resolve(ctx context.Context, service string) ([]Endpoint, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(ctx context.Context, service string) ([]Endpoint, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	return f(ctx, service)
+}
+
+// Option configures a Directory.
+type Option func(*Directory)
+
+// WithBalancer sets how endpoints are picked. Defaults to RoundRobin.
+func WithBalancer(b Balancer) Option {
+	return func(d *Directory) {
+		d.balancer = b
+	}
+}
+
+// WithRefreshInterval sets how long resolved endpoints are cached.
+// Defaults to 30 seconds.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(d *Directory) {
+		d.refresh = interval
+	}
+}
+
+// WithOutlierEjection ejects an endpoint for ejectFor after failures
+// consecutive failed requests, never ejecting more than half of a
+// service's endpoints. Defaults to 5 failures and 30 seconds; zero
+// failures disables ejection.
+func WithOutlierEjection(failures int, ejectFor time.Duration) Option {
+	return func(d *Directory) {
+		d.ejectAfter = failures
+		d.ejectFor = ejectFor
+	}
+}
+
+// Directory resolves services and balances requests across their
+// endpoints. It is safe for concurrent use.
+type Directory struct {
+	resolver   Resolver
+	balancer   Balancer
+	refresh    time.Duration
+	ejectAfter int
+	ejectFor   time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	services map[string]*service
+}
+
+type service struct {
+	mu         sync.Mutex
+	endpoints  []*endpoint
+	resolvedAt time.Time
+
+	// resolving is closed when the resolve in progress, if any, ends; err
+	// is the error of the last one.
+	resolving chan struct{}
+	err       error
+}
+
+type endpoint struct {
+	Endpoint
+	outstanding atomic.Int64
+
+	// Guarded by service.mu.
+	failures     int
+	ejectedUntil time.Time
+}
+
+func New(r Resolver, opts ...Option) *Directory {
+	d := &Directory{
+		resolver:   r,
+		balancer:   RoundRobin(),
+		refresh:    30 * time.Second,
+		ejectAfter: 5,
+		ejectFor:   30 * time.Second,
+		now:        time.Now,
+		services:   make(map[string]*service),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Endpoints returns the endpoints of name. Cached endpoints older than the
+// refresh interval are returned while they are resolved again in the
+// background; only the first call for a service waits for the resolver.
+// If resolving fails, the stale endpoints are kept for another interval.
+func (d *Directory) Endpoints(ctx context.Context, name string) ([]Endpoint, error) {
+	s, err := d.service(ctx, name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	eps := make([]Endpoint, len(s.endpoints))
+	for i, ep := range s.endpoints {
+		eps[i] = ep.Endpoint
+	}
+	return eps, nil
+}
+
+// Done ends a request made to an endpoint returned by Pick. Call it with
+// the request's outcome, or call its Release method instead for requests
+// that never reached the endpoint; later calls do nothing.
+type Done func(err error)
+
+// errNotSent is what Release reports to Done.
+var errNotSent = errors.New("discovery: request not sent")
+
+// Release ends the request without counting it towards ejection, either
+// way.
+func (d Done) Release() {
+	d(errNotSent)
+}
+
+// Pick chooses an endpoint of name for one request, skipping ejected
+// endpoints unless all of them are. The caller must call done with the
+// request's outcome; a non-nil error counts towards ejection.
+func (d *Directory) Pick(ctx context.Context, name string) (Endpoint, Done, error) {
+	s, err := d.service(ctx, name, false)
+	if err != nil {
+		return Endpoint{}, nil, err
+	}
+	return d.pick(ctx, s)
+}
+
+// PickCached is Pick among the endpoints already cached for name. It
+// never resolves or blocks, for callers such as gRPC pickers, and returns
+// ErrNoEndpoints if nothing is cached.
+func (d *Directory) PickCached(ctx context.Context, name string) (Endpoint, Done, error) {
+	d.mu.Lock()
+	s, ok := d.services[name]
+	d.mu.Unlock()
+	if !ok {
+		return Endpoint{}, nil, ErrNoEndpoints
+	}
+	return d.pick(ctx, s)
+}
+
+func (d *Directory) pick(ctx context.Context, s *service) (Endpoint, Done, error) {
+	s.mu.Lock()
+	if len(s.endpoints) == 0 {
+		s.mu.Unlock()
+		return Endpoint{}, nil, ErrNoEndpoints
+	}
+	now := d.now()
+	healthy := make([]*endpoint, 0, len(s.endpoints))
+	for _, ep := range s.endpoints {
+		if !now.Before(ep.ejectedUntil) {
+			healthy = append(healthy, ep)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = s.endpoints
+	}
+
+	candidates := make([]Candidate, len(healthy))
+	for i, ep := range healthy {
+		candidates[i] = Candidate{Endpoint: ep.Endpoint, Outstanding: ep.outstanding.Load()}
+	}
+	ep := healthy[d.balancer.Pick(candidates, HashKey(ctx))]
+	s.mu.Unlock()
+
+	ep.outstanding.Add(1)
+	var once sync.Once
+	return ep.Endpoint, func(err error) {
+		once.Do(func() {
+			ep.outstanding.Add(-1)
+			d.record(s, ep, err)
+		})
+	}, nil
+}
+
+// Watch calls fn with the endpoints of name whenever they change, and
+// with any resolution error, until ctx ends.
+func (d *Directory) Watch(ctx context.Context, name string, fn func([]Endpoint, error)) {
+	var last []Endpoint
+	first := true
+	ticker := time.NewTicker(d.refresh)
+	defer ticker.Stop()
+
+	for {
+		var eps []Endpoint
+		s, err := d.service(ctx, name, true)
+		if err == nil {
+			s.mu.Lock()
+			eps = make([]Endpoint, len(s.endpoints))
+			for i, ep := range s.endpoints {
+				eps[i] = ep.Endpoint
+			}
+			s.mu.Unlock()
+		}
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			fn(nil, err)
+		case first || !slices.Equal(eps, last):
+			fn(eps, nil)
+			last, first = eps, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolveTimeout bounds each call to the Resolver.
+const resolveTimeout = 10 * time.Second
+
+// service returns the state of name, starting a resolve in the background
+// when it is stale. It waits for that resolve if nothing is cached yet,
+// or if wait is set, until ctx ends.
+func (d *Directory) service(ctx context.Context, name string, wait bool) (*service, error) {
+	d.mu.Lock()
+	s, ok := d.services[name]
+	if !ok {
+		s = &service{}
+		d.services[name] = s
+	}
+	d.mu.Unlock()
+
+	s.mu.Lock()
+	if s.endpoints != nil && d.now().Sub(s.resolvedAt) < d.refresh {
+		s.mu.Unlock()
+		return s, nil
+	}
+	done := s.resolving
+	if done == nil {
+		done = make(chan struct{})
+		s.resolving = done
+		go d.resolve(name, s, done)
+	}
+	cached := s.endpoints != nil
+	s.mu.Unlock()
+
+	if cached && !wait {
+		return s, nil
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.endpoints == nil {
+		return nil, s.err
+	}
+	return s, nil
+}
+
+// resolve looks name up without holding s.mu, so that picks carry on with
+// the cached endpoints meanwhile, and swaps the result in.
+func (d *Directory) resolve(name string, s *service, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	resolved, err := d.resolver.Resolve(ctx, name)
+	if err == nil && len(resolved) == 0 {
+		err = ErrNoEndpoints
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer close(done)
+	s.resolving = nil
+	s.err = err
+	if err != nil {
+		if s.endpoints != nil {
+			s.resolvedAt = d.now()
+		}
+		return
+	}
+
+	// Keep the load and ejection state of endpoints that remain.
+	previous := make(map[string]*endpoint, len(s.endpoints))
+	for _, ep := range s.endpoints {
+		previous[ep.Addr] = ep
+	}
+	endpoints := make([]*endpoint, len(resolved))
+	for i, r := range resolved {
+		if ep, ok := previous[r.Addr]; ok {
+			ep.Endpoint = r
+			endpoints[i] = ep
+		} else {
+			endpoints[i] = &endpoint{Endpoint: r}
+		}
+	}
+	s.endpoints = endpoints
+	s.resolvedAt = d.now()
+}
+
+func (d *Directory) record(s *service, ep *endpoint, err error) {
+	if d.ejectAfter <= 0 || errors.Is(err, errNotSent) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		ep.failures = 0
+		return
+	}
+	if ep.failures++; ep.failures < d.ejectAfter {
+		return
+	}
+
+	now := d.now()
+	ejected := 0
+	for _, other := range s.endpoints {
+		if now.Before(other.ejectedUntil) {
+			ejected++
+		}
+	}
+	if (ejected+1)*2 <= len(s.endpoints) {
+		ep.ejectedUntil = now.Add(d.ejectFor)
+		ep.failures = 0
+	}
+}
+
+type hashKey struct{}
+
+// WithHashKey returns a context whose requests are routed by key when the
+// balancer is ConsistentHash, so the same key keeps reaching the same
+// endpoint.
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+// HashKey returns the key set with WithHashKey, or "".
+func HashKey(ctx context.Context) string {
+	key, _ := ctx.Value(hashKey{}).(string)
+	return key
+}
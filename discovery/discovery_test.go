@@ -0,0 +1,290 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("endpoint down")
+
+func TestRoundRobinAcrossStaticEndpoints(t *testing.T) {
+	dir := New(Static(map[string][]string{"orders": {"a:1", "b:1", "c:1"}}))
+
+	counts := map[string]int{}
+	for i := 0; i < 9; i++ {
+		ep, done, err := dir.Pick(context.Background(), "orders")
+		if err != nil {
+			t.Fatalf("Pick failed: %v", err)
+		}
+		done(nil)
+		counts[ep.Addr]++
+	}
+	for _, addr := range []string{"a:1", "b:1", "c:1"} {
+		if counts[addr] != 3 {
+			t.Fatalf("Expected an even spread, got %v", counts)
+		}
+	}
+
+	if _, _, err := dir.Pick(context.Background(), "payments"); !errors.Is(err, ErrUnknownService) {
+		t.Fatalf("Expected ErrUnknownService, got %v", err)
+	}
+}
+
+func TestLoadAwareBalancers(t *testing.T) {
+	candidates := []Candidate{
+		{Endpoint: Endpoint{Addr: "busy"}, Outstanding: 10},
+		{Endpoint: Endpoint{Addr: "idle"}, Outstanding: 0},
+	}
+	if i := LeastOutstanding().Pick(candidates, ""); candidates[i].Addr != "idle" {
+		t.Fatalf("LeastOutstanding picked %s", candidates[i].Addr)
+	}
+	// With two candidates, both are always compared.
+	for i := 0; i < 20; i++ {
+		if j := PowerOfTwoChoices().Pick(candidates, ""); candidates[j].Addr != "idle" {
+			t.Fatalf("PowerOfTwoChoices picked %s", candidates[j].Addr)
+		}
+	}
+
+	dir := New(Static(map[string][]string{"orders": {"a:1", "b:1"}}), WithBalancer(LeastOutstanding()))
+	first, done, _ := dir.Pick(context.Background(), "orders")
+	second, _, _ := dir.Pick(context.Background(), "orders")
+	if first.Addr == second.Addr {
+		t.Fatal("Expected the second pick to avoid the endpoint with a request in flight")
+	}
+	done(nil)
+}
+
+func TestConsistentHashMovesOnlyRemovedKeys(t *testing.T) {
+	b := ConsistentHash(0)
+	all := []Candidate{{Endpoint: Endpoint{Addr: "a:1"}}, {Endpoint: Endpoint{Addr: "b:1"}}, {Endpoint: Endpoint{Addr: "c:1"}}}
+	without := []Candidate{all[0], all[2]}
+
+	owners := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("customer-%d", i)
+		before := all[b.Pick(all, key)].Addr
+		after := without[b.Pick(without, key)].Addr
+		owners[before]++
+
+		if before != "b:1" && before != after {
+			t.Fatalf("Key %s moved from %s to %s although its endpoint remained", key, before, after)
+		}
+		if again := all[b.Pick(all, key)].Addr; again != before {
+			t.Fatalf("Key %s is not stable: %s then %s", key, before, again)
+		}
+	}
+	for addr, n := range owners {
+		if n < 200 {
+			t.Fatalf("Expected keys spread over endpoints, %s got %d of 1000", addr, n)
+		}
+	}
+}
+
+func TestHashKeyRoutesThroughDirectory(t *testing.T) {
+	dir := New(Static(map[string][]string{"carts": {"a:1", "b:1", "c:1"}}), WithBalancer(ConsistentHash(0)))
+	ctx := WithHashKey(context.Background(), "cart-42")
+
+	first, done, _ := dir.Pick(ctx, "carts")
+	done(nil)
+	for i := 0; i < 10; i++ {
+		ep, done, _ := dir.Pick(ctx, "carts")
+		done(nil)
+		if ep != first {
+			t.Fatalf("Expected cart-42 to stick to %s, got %s", first.Addr, ep.Addr)
+		}
+	}
+}
+
+func TestOutlierEjection(t *testing.T) {
+	now := time.Unix(1000, 0)
+	dir := New(Static(map[string][]string{"orders": {"bad:1", "good:1", "ok:1", "fine:1"}}),
+		WithOutlierEjection(2, time.Minute))
+	dir.now = func() time.Time { return now }
+
+	fail := func(addr string) {
+		for {
+			ep, done, _ := dir.Pick(context.Background(), "orders")
+			if ep.Addr == addr {
+				done(errDown)
+				return
+			}
+			done(nil)
+		}
+	}
+	fail("bad:1")
+	fail("bad:1")
+
+	for i := 0; i < 12; i++ {
+		ep, done, _ := dir.Pick(context.Background(), "orders")
+		done(nil)
+		if ep.Addr == "bad:1" {
+			t.Fatal("Expected bad:1 to be ejected")
+		}
+	}
+
+	// At most half of the endpoints may be ejected at once.
+	fail("good:1")
+	fail("good:1")
+	fail("ok:1")
+	fail("ok:1")
+	seen := map[string]bool{}
+	for i := 0; i < 12; i++ {
+		ep, done, _ := dir.Pick(context.Background(), "orders")
+		done(nil)
+		seen[ep.Addr] = true
+	}
+	if len(seen) != 2 || !seen["ok:1"] || !seen["fine:1"] {
+		t.Fatalf("Expected only bad:1 and good:1 to be ejected, saw %v", seen)
+	}
+
+	now = now.Add(time.Minute)
+	seen = map[string]bool{}
+	for i := 0; i < 12; i++ {
+		ep, done, _ := dir.Pick(context.Background(), "orders")
+		done(nil)
+		seen[ep.Addr] = true
+	}
+	if !seen["bad:1"] {
+		t.Fatal("Expected bad:1 to return after the ejection period")
+	}
+}
+
+func TestStaleEndpointsSurviveResolverErrors(t *testing.T) {
+	var mu sync.Mutex
+	fail := false
+	r := ResolverFunc(func(ctx context.Context, name string) ([]Endpoint, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return nil, errDown
+		}
+		return []Endpoint{{Addr: "a:1"}}, nil
+	})
+	dir := New(r, WithRefreshInterval(time.Nanosecond))
+
+	if _, err := dir.Endpoints(context.Background(), "orders"); err != nil {
+		t.Fatalf("Endpoints failed: %v", err)
+	}
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	eps, err := dir.Endpoints(context.Background(), "orders")
+	if err != nil || len(eps) != 1 {
+		t.Fatalf("Expected stale endpoints, got %v, %v", eps, err)
+	}
+}
+
+func TestRefreshDoesNotBlockPicks(t *testing.T) {
+	release := make(chan struct{})
+	var calls sync.WaitGroup
+	first := true
+	r := ResolverFunc(func(ctx context.Context, name string) ([]Endpoint, error) {
+		if !first {
+			defer calls.Done()
+			<-release
+		}
+		first = false
+		return []Endpoint{{Addr: "a:1"}}, nil
+	})
+	now := time.Unix(1000, 0)
+	var mu sync.Mutex
+	dir := New(r)
+	dir.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	if _, _, err := dir.Pick(context.Background(), "orders"); err != nil {
+		t.Fatalf("Pick failed: %v", err)
+	}
+	mu.Lock()
+	now = now.Add(time.Hour)
+	mu.Unlock()
+
+	// The refresh hangs in the resolver while picks go on, and a caller
+	// with an expired context does not cut it short.
+	calls.Add(1)
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 3; i++ {
+		if ep, done, err := dir.Pick(expired, "orders"); err != nil || ep.Addr != "a:1" {
+			t.Fatalf("Expected the cached endpoint during the refresh, got %v, %v", ep, err)
+		} else {
+			done(nil)
+		}
+	}
+	close(release)
+	calls.Wait()
+}
+
+func TestReleaseDoesNotResetFailures(t *testing.T) {
+	dir := New(Static(map[string][]string{"orders": {"a:1", "b:1"}}), WithOutlierEjection(2, time.Minute))
+
+	pick := func() (Endpoint, Done) {
+		for {
+			ep, done, _ := dir.Pick(context.Background(), "orders")
+			if ep.Addr == "a:1" {
+				return ep, done
+			}
+			done(nil)
+		}
+	}
+	_, done := pick()
+	done(errDown)
+	_, done = pick()
+	done.Release()
+	_, done = pick()
+	done(errDown)
+
+	for i := 0; i < 4; i++ {
+		ep, done, _ := dir.PickCached(context.Background(), "orders")
+		done(nil)
+		if ep.Addr == "a:1" {
+			t.Fatal("Expected a:1 to be ejected after two failures around a release")
+		}
+	}
+}
+
+func TestFileResolverReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	os.WriteFile(path, []byte(`{"orders": ["a:1"]}`), 0o600)
+
+	dir := New(File(path), WithRefreshInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan []Endpoint, 4)
+	go dir.Watch(ctx, "orders", func(eps []Endpoint, err error) {
+		if err == nil {
+			updates <- eps
+		}
+	})
+
+	if eps := <-updates; len(eps) != 1 || eps[0].Addr != "a:1" {
+		t.Fatalf("Unexpected initial endpoints %v", eps)
+	}
+
+	os.WriteFile(path, []byte(`{"orders": ["a:1", "b:2"]}`), 0o600)
+	select {
+	case eps := <-updates:
+		if len(eps) != 2 || eps[1].Addr != "b:2" {
+			t.Fatalf("Unexpected reloaded endpoints %v", eps)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("File change was not picked up")
+	}
+
+	// A broken file keeps the last good list.
+	os.WriteFile(path, []byte(`{not json`), 0o600)
+	time.Sleep(30 * time.Millisecond)
+	if eps, err := dir.Endpoints(context.Background(), "orders"); err != nil || len(eps) != 2 {
+		t.Fatalf("Expected the last good list, got %v, %v", eps, err)
+	}
+}
@@ -0,0 +1,169 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Static resolves services from a fixed map of service names to
+// host:port addresses.
+func Static(services map[string][]string) Resolver {
+	return ResolverFunc(func(_ context.Context, name string) ([]Endpoint, error) {
+		addrs, ok := services[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownService, name)
+		}
+		return endpoints(addrs), nil
+	})
+}
+
+// DNS resolves a service name through its A and AAAA records. Names may
+// carry a port ("orders:8080"); otherwise Port is used.
+type DNS struct {
+	Port string
+
+	// Resolver defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+func (r DNS) Resolve(ctx context.Context, name string) ([]Endpoint, error) {
+	host, port := name, r.Port
+	if h, p, err := net.SplitHostPort(name); err == nil {
+		host, port = h, p
+	}
+	if port == "" {
+		return nil, fmt.Errorf("discovery: no port for %s", name)
+	}
+
+	addrs, err := resolver(r.Resolver).LookupHost(ctx, host)
+	if err != nil {
+		return nil, notFound(err, name)
+	}
+	eps := make([]Endpoint, len(addrs))
+	for i, addr := range addrs {
+		eps[i] = Endpoint{Addr: net.JoinHostPort(addr, port)}
+	}
+	return eps, nil
+}
+
+// SRV resolves a service through its SRV records, which carry the port
+// and weight of every instance. With Service and Proto set, looking up
+// "orders" queries _Service._Proto.orders; without them the name is
+// queried as given.
+type SRV struct {
+	Service string
+	Proto   string
+
+	// Resolver defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+func (r SRV) Resolve(ctx context.Context, name string) ([]Endpoint, error) {
+	_, records, err := resolver(r.Resolver).LookupSRV(ctx, r.Service, r.Proto, name)
+	if err != nil {
+		return nil, notFound(err, name)
+	}
+	eps := make([]Endpoint, len(records))
+	for i, rec := range records {
+		host := strings.TrimSuffix(rec.Target, ".")
+		eps[i] = Endpoint{
+			Addr:   net.JoinHostPort(host, strconv.Itoa(int(rec.Port))),
+			Weight: int(rec.Weight),
+		}
+	}
+	return eps, nil
+}
+
+func resolver(r *net.Resolver) *net.Resolver {
+	if r == nil {
+		return net.DefaultResolver
+	}
+	return r
+}
+
+func notFound(err error, name string) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return fmt.Errorf("%w: %s: %v", ErrUnknownService, name, err)
+	}
+	return err
+}
+
+// File resolves services from a JSON file mapping service names to
+// host:port addresses, such as {"orders": ["10.0.0.1:8080"]}. Each
+// Resolve checks the file and reads it again if its modification time or
+// size changed; the Directory's refresh interval sets how often that is.
+func File(path string) Resolver {
+	return &fileResolver{path: path}
+}
+
+type fileResolver struct {
+	path string
+
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	services  map[string][]string
+	loadError error
+}
+
+func (r *fileResolver) Resolve(_ context.Context, name string) ([]Endpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reload()
+	if r.services == nil {
+		return nil, r.loadError
+	}
+
+	addrs, ok := r.services[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownService, name)
+	}
+	return endpoints(addrs), nil
+}
+
+// reload re-reads the file if it changed. A file that cannot be read or
+// parsed keeps the last good contents in use. r.mu must be held.
+func (r *fileResolver) reload() {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		r.loadError = fmt.Errorf("discovery: %w", err)
+		return
+	}
+	if r.services != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		r.loadError = fmt.Errorf("discovery: %w", err)
+		return
+	}
+	var services map[string][]string
+	if err := json.Unmarshal(data, &services); err != nil {
+		r.loadError = fmt.Errorf("discovery: parse %s: %w", r.path, err)
+		return
+	}
+
+	r.services = services
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	r.loadError = nil
+}
+
+func endpoints(addrs []string) []Endpoint {
+	eps := make([]Endpoint, len(addrs))
+	for i, addr := range addrs {
+		eps[i] = Endpoint{Addr: addr}
+	}
+	return eps
+}
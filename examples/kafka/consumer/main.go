@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/festus/microkit/adapters/kafka"
-	"github.com/festus/microkit/internal/retry"
+	"github.com/festus/microkit/retry"
 )
 
 func main() {
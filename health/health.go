@@ -0,0 +1,200 @@
+// Package health aggregates liveness and readiness checks and serves them
+// as JSON for Kubernetes probes.
+//
+// rabbitmq.Connection, kafka.Connection and the HTTP and gRPC clients all
+// implement Checker.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Checker reports whether a dependency is usable. Check must respect ctx.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Status is the outcome of a check or of a whole report.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status     Status `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the outcome of a set of checks. Status is down if any check is.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Option configures an Aggregator.
+type Option func(*Aggregator)
+
+// WithTimeout bounds each individual check. Defaults to 5 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(a *Aggregator) {
+		a.timeout = d
+	}
+}
+
+// Aggregator runs named liveness and readiness checks concurrently.
+type Aggregator struct {
+	timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  map[string]Checker
+	readiness map[string]Checker
+}
+
+func New(opts ...Option) *Aggregator {
+	a := &Aggregator{
+		timeout:   5 * time.Second,
+		liveness:  make(map[string]Checker),
+		readiness: make(map[string]Checker),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// AddLiveness registers a check that tells whether the process should be
+// restarted. Keep these cheap and free of external dependencies.
+func (a *Aggregator) AddLiveness(name string, c Checker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.liveness[name] = c
+}
+
+// AddReadiness registers a check that tells whether the process can serve
+// traffic, such as broker or downstream connectivity.
+func (a *Aggregator) AddReadiness(name string, c Checker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.readiness[name] = c
+}
+
+// Live runs the liveness checks.
+func (a *Aggregator) Live(ctx context.Context) Report {
+	return a.run(ctx, a.liveness)
+}
+
+// Ready runs the readiness checks.
+func (a *Aggregator) Ready(ctx context.Context) Report {
+	return a.run(ctx, a.readiness)
+}
+
+// LivenessHandler serves the liveness report, with status 503 when down.
+func (a *Aggregator) LivenessHandler() http.Handler {
+	return reportHandler(a.Live)
+}
+
+// ReadinessHandler serves the readiness report, with status 503 when down.
+func (a *Aggregator) ReadinessHandler() http.Handler {
+	return reportHandler(a.Ready)
+}
+
+// Handler serves liveness on /livez and readiness on /readyz.
+func (a *Aggregator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/livez", a.LivenessHandler())
+	mux.Handle("/readyz", a.ReadinessHandler())
+	return mux
+}
+
+func (a *Aggregator) run(ctx context.Context, checks map[string]Checker) Report {
+	a.mu.RLock()
+	snapshot := make(map[string]Checker, len(checks))
+	for name, c := range checks {
+		snapshot[name] = c
+	}
+	a.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(snapshot))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range snapshot {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := a.check(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if res.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (a *Aggregator) check(ctx context.Context, c Checker) Result {
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errc <- fmt.Errorf("health: check panicked: %v", r)
+			}
+		}()
+		errc <- c.Check(ctx)
+	}()
+
+	// A check that ignores ctx must not hold up the probe.
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusUp, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func reportHandler(run func(context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != StatusUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
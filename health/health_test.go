@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/adapters/grpc"
+	microhttp "github.com/festus/microkit/adapters/http"
+	"github.com/festus/microkit/adapters/kafka"
+	"github.com/festus/microkit/adapters/rabbitmq"
+	grpclib "google.golang.org/grpc"
+)
+
+var (
+	_ Checker = (*rabbitmq.Connection)(nil)
+	_ Checker = (*kafka.Connection)(nil)
+	_ Checker = (*microhttp.Client)(nil)
+	_ Checker = (*grpc.Client)(nil)
+)
+
+func TestAggregator(t *testing.T) {
+	agg := New(WithTimeout(50 * time.Millisecond))
+	agg.AddLiveness("process", CheckerFunc(func(ctx context.Context) error { return nil }))
+	agg.AddReadiness("db", CheckerFunc(func(ctx context.Context) error { return nil }))
+	agg.AddReadiness("broker", CheckerFunc(func(ctx context.Context) error { return errors.New("unreachable") }))
+	agg.AddReadiness("stuck", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second) // ignores ctx on purpose
+		return nil
+	}))
+
+	srv := httptest.NewServer(agg.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 from /livez, got %d", resp.StatusCode)
+	}
+
+	start := time.Now()
+	resp, err = http.Get(srv.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("Readiness should not wait for checks that ignore ctx")
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 from /readyz, got %d", resp.StatusCode)
+	}
+
+	var report Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Checks["db"].Status != StatusUp {
+		t.Fatalf("Expected db up, got %+v", report.Checks["db"])
+	}
+	if report.Checks["broker"].Error != "unreachable" {
+		t.Fatalf("Expected broker error, got %+v", report.Checks["broker"])
+	}
+	if report.Checks["stuck"].Status != StatusDown {
+		t.Fatalf("Expected stuck check to time out, got %+v", report.Checks["stuck"])
+	}
+}
+
+func TestClientChecks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ping.Close()
+
+	if err := microhttp.NewClient(time.Second, microhttp.WithHealthCheckURL(ping.URL+"/healthz")).Check(ctx); err != nil {
+		t.Fatalf("Expected healthy HTTP client, got %v", err)
+	}
+	if err := microhttp.NewClient(time.Second, microhttp.WithHealthCheckURL(ping.URL+"/missing")).Check(ctx); err == nil {
+		t.Fatal("Expected 404 to be unhealthy")
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpclib.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	client, err := grpc.NewClient(lis.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Check(ctx); err != nil {
+		t.Fatalf("Expected gRPC connection to become ready, got %v", err)
+	}
+
+	if err := kafka.NewConnection([]string{"127.0.0.1:1"}).Check(ctx); err == nil {
+		t.Fatal("Expected unreachable Kafka broker to be unhealthy")
+	}
+
+	// A broker that accepts connections and never answers.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			c, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := kafka.NewConnection([]string{silent.Addr().String()}).Check(short); err == nil || time.Since(start) > time.Second {
+		t.Fatalf("Expected a silent Kafka broker to fail within the deadline, got %v after %v", err, time.Since(start))
+	}
+}
@@ -0,0 +1,206 @@
+// Package hedge sends backup copies of slow requests and takes whichever
+// answers first.
+//
+// A Hedger starts the first attempt, waits for its delay, then starts up
+// to MaxHedges more attempts one delay apart while none has succeeded.
+// The first success wins and the others are cancelled. The delay is
+// either fixed or tracks a percentile of recently observed latencies, and
+// an optional budget bounds how many extra requests hedging may add.
+//
+// Hedge only idempotent requests: every attempt may reach the server.
+package hedge
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Config configures a Hedger.
+type Config struct {
+	// Delay is how long to wait for an attempt before starting the next.
+	// With Percentile set it is used until MinSamples latencies have been
+	// observed.
+	Delay time.Duration
+
+	// MaxHedges is the number of attempts started after the first.
+	MaxHedges int
+
+	// Percentile derives the delay from the latency of recent successful
+	// calls, such as 0.95 to hedge calls slower than the p95. Zero keeps
+	// Delay fixed.
+	Percentile float64
+
+	// Window is the number of recent latencies kept. Defaults to 1000.
+	Window int
+
+	// MinSamples is how many latencies must be seen before Percentile
+	// replaces Delay. Defaults to 100.
+	MinSamples int
+
+	// Budget caps hedges at roughly this fraction of calls, such as 0.1
+	// for at most 10% extra requests. Zero means no cap.
+	Budget float64
+}
+
+// The budget is kept in thousandths of a hedge so that fractions such as
+// 0.1 add up exactly. maxTokens bounds how many unused hedges it can save.
+const (
+	tokenScale = 1000
+	maxTokens  = 10 * tokenScale
+)
+
+// Hedger decides when to hedge. Share one between calls to the same
+// backend so the latency percentile and budget see all of them. It is
+// safe for concurrent use.
+type Hedger struct {
+	cfg Config
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	seen    int
+	delay   time.Duration
+	tokens  int64
+}
+
+func New(cfg Config) *Hedger {
+	if cfg.Window <= 0 {
+		cfg.Window = 1000
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 100
+	}
+	return &Hedger{
+		cfg:     cfg,
+		samples: make([]time.Duration, 0, cfg.Window),
+		delay:   cfg.Delay,
+		tokens:  tokenScale,
+	}
+}
+
+// Delay returns how long to wait before the next hedge.
+func (h *Hedger) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay
+}
+
+// Observe records the latency of a successful call.
+func (h *Hedger) Observe(d time.Duration) {
+	if h.cfg.Percentile <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < h.cfg.Window {
+		h.samples = append(h.samples, d)
+	} else {
+		h.samples[h.next] = d
+		h.next = (h.next + 1) % h.cfg.Window
+	}
+
+	// Sorting the window is cheap enough every few calls.
+	if h.seen++; h.seen >= h.cfg.MinSamples && h.seen%16 == 0 {
+		sorted := slices.Clone(h.samples)
+		slices.Sort(sorted)
+		i := int(float64(len(sorted)-1) * h.cfg.Percentile)
+		h.delay = sorted[min(i, len(sorted)-1)]
+	}
+}
+
+// credit adds a call's share of the budget.
+func (h *Hedger) credit() {
+	if h.cfg.Budget <= 0 {
+		return
+	}
+	h.mu.Lock()
+	h.tokens = min(h.tokens+int64(h.cfg.Budget*tokenScale), maxTokens)
+	h.mu.Unlock()
+}
+
+// take reports whether the budget allows one more hedge, and spends it.
+func (h *Hedger) take() bool {
+	if h.cfg.Budget <= 0 {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < tokenScale {
+		return false
+	}
+	h.tokens -= tokenScale
+	return true
+}
+
+type result[T any] struct {
+	value   T
+	err     error
+	elapsed time.Duration
+}
+
+// Do calls fn and hedges it as configured by h, numbering attempts from
+// zero. It returns the first successful result, or the last error once
+// every attempt started has failed. Every attempt's context is cancelled
+// when Do returns; results of attempts that succeed after the winner are
+// passed to discard, if not nil.
+func Do[T any](ctx context.Context, h *Hedger, fn func(ctx context.Context, attempt int) (T, error), discard func(T)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result[T], h.cfg.MaxHedges+1)
+	running, started := 0, 0
+	launch := func() {
+		attempt := started
+		running++
+		started++
+		go func() {
+			start := time.Now()
+			v, err := fn(ctx, attempt)
+			results <- result[T]{value: v, err: err, elapsed: time.Since(start)}
+		}()
+	}
+	// drain hands the results of attempts still running to discard.
+	drain := func() {
+		go func(n int) {
+			for ; n > 0; n-- {
+				if r := <-results; r.err == nil && discard != nil {
+					discard(r.value)
+				}
+			}
+		}(running)
+	}
+
+	h.credit()
+	launch()
+
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+
+	var zero T
+	for {
+		select {
+		case r := <-results:
+			running--
+			if r.err == nil {
+				h.Observe(r.elapsed)
+				drain()
+				return r.value, nil
+			}
+			if running == 0 {
+				return zero, r.err
+			}
+		case <-timer.C:
+			if started <= h.cfg.MaxHedges && h.take() {
+				launch()
+				timer.Reset(h.Delay())
+			}
+		case <-ctx.Done():
+			drain()
+			return zero, ctx.Err()
+		}
+	}
+}
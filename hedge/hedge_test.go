@@ -0,0 +1,136 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoReturnsFirstSuccess(t *testing.T) {
+	h := New(Config{Delay: 10 * time.Millisecond, MaxHedges: 2})
+
+	var cancelled atomic.Int32
+	got, err := Do(context.Background(), h, func(ctx context.Context, attempt int) (int, error) {
+		if attempt == 0 {
+			<-ctx.Done()
+			cancelled.Add(1)
+			return 0, ctx.Err()
+		}
+		return attempt, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if got != 1 {
+		t.Fatalf("Expected the first hedge to win, got attempt %d", got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for cancelled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if cancelled.Load() != 1 {
+		t.Fatal("Expected the slow attempt to be cancelled")
+	}
+}
+
+func TestDoFastCallIsNotHedged(t *testing.T) {
+	h := New(Config{Delay: time.Second, MaxHedges: 2})
+
+	var calls atomic.Int32
+	if _, err := Do(context.Background(), h, func(context.Context, int) (int, error) {
+		calls.Add(1)
+		return 0, nil
+	}, nil); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", calls.Load())
+	}
+}
+
+func TestDoReturnsErrorWhenAllFail(t *testing.T) {
+	h := New(Config{Delay: 5 * time.Millisecond, MaxHedges: 2})
+	boom := errors.New("boom")
+
+	var calls atomic.Int32
+	_, err := Do(context.Background(), h, func(_ context.Context, attempt int) (int, error) {
+		calls.Add(1)
+		time.Sleep(time.Duration(3-attempt) * 10 * time.Millisecond)
+		return 0, boom
+	}, nil)
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected boom, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestDoDiscardsLateWinners(t *testing.T) {
+	h := New(Config{Delay: 5 * time.Millisecond, MaxHedges: 1})
+
+	discarded := make(chan int, 1)
+	release := make(chan struct{})
+	got, err := Do(context.Background(), h, func(_ context.Context, attempt int) (int, error) {
+		if attempt == 0 {
+			<-release
+		}
+		return attempt, nil
+	}, func(v int) { discarded <- v })
+	if err != nil || got != 1 {
+		t.Fatalf("Expected attempt 1, got %d, %v", got, err)
+	}
+
+	close(release)
+	select {
+	case v := <-discarded:
+		if v != 0 {
+			t.Fatalf("Expected attempt 0 to be discarded, got %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the late result to be discarded")
+	}
+}
+
+func TestBudgetLimitsHedges(t *testing.T) {
+	h := New(Config{Delay: time.Millisecond, MaxHedges: 1, Budget: 0.1})
+
+	var hedges atomic.Int32
+	for i := 0; i < 20; i++ {
+		Do(context.Background(), h, func(ctx context.Context, attempt int) (int, error) {
+			if attempt > 0 {
+				hedges.Add(1)
+				return 0, nil
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(20 * time.Millisecond):
+			}
+			return 0, nil
+		}, nil)
+	}
+	// One saved-up token plus 0.1 per call.
+	if n := hedges.Load(); n != 3 {
+		t.Fatalf("Expected 3 hedges within the budget, got %d", n)
+	}
+}
+
+func TestPercentileDelay(t *testing.T) {
+	h := New(Config{Delay: time.Second, Percentile: 0.9, MinSamples: 10, Window: 100})
+
+	for i := 1; i <= 100; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+	if d := h.Delay(); d < 85*time.Millisecond || d > 95*time.Millisecond {
+		t.Fatalf("Expected a delay near the p90 of 90ms, got %v", d)
+	}
+
+	fixed := New(Config{Delay: time.Second})
+	fixed.Observe(time.Millisecond)
+	if d := fixed.Delay(); d != time.Second {
+		t.Fatalf("Expected the fixed delay, got %v", d)
+	}
+}
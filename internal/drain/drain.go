@@ -0,0 +1,69 @@
+// Package drain waits for in-flight work during shutdown.
+package drain
+
+import (
+	"context"
+	"sync"
+)
+
+// Wait blocks until wg is done or ctx ends, returning ctx.Err() in the
+// latter case.
+func Wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending counts work in progress that, unlike with a sync.WaitGroup,
+// may start while Wait is running. The zero value is ready to use.
+type Pending struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+func (p *Pending) Add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.n++
+}
+
+func (p *Pending) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.n--
+	if p.n == 0 {
+		close(p.idle)
+	}
+}
+
+// Wait blocks until no work is in progress or ctx ends, returning
+// ctx.Err() in the latter case.
+func (p *Pending) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	idle := p.idle
+	n := p.n
+	p.mu.Unlock()
+	if n == 0 {
+		return nil
+	}
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,57 @@
+// Package instrument holds the tracing, metrics and logging options
+// shared by the messaging adapters, each of which declares its own Option
+// type over them.
+package instrument
+
+import (
+	"log/slog"
+
+	"github.com/festus/microkit/internal/logging"
+	"github.com/festus/microkit/metrics"
+	"github.com/festus/microkit/tracing"
+)
+
+// Options is what an adapter component reports to.
+type Options struct {
+	Tracer  *tracing.Tracer
+	Metrics metrics.Recorder
+	Logger  *slog.Logger
+
+	// LogPayloads includes message payloads in log records.
+	LogPayloads bool
+}
+
+// New applies opts over the defaults: a tracer on the global OpenTelemetry
+// providers, no metrics, and a logger that discards everything.
+func New[Option ~func(*Options)](opts []Option) Options {
+	o := Options{
+		Tracer:  tracing.New(),
+		Metrics: metrics.Nop{},
+		Logger:  logging.Discard(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SetTracer replaces the tracer unless t is nil.
+func (o *Options) SetTracer(t *tracing.Tracer) {
+	if t != nil {
+		o.Tracer = t
+	}
+}
+
+// SetMetrics replaces the recorder unless r is nil.
+func (o *Options) SetMetrics(r metrics.Recorder) {
+	if r != nil {
+		o.Metrics = r
+	}
+}
+
+// SetLogger replaces the logger unless l is nil.
+func (o *Options) SetLogger(l *slog.Logger) {
+	if l != nil {
+		o.Logger = l
+	}
+}
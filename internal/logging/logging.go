@@ -0,0 +1,29 @@
+// Package logging holds the slog helpers shared by the adapters.
+package logging
+
+import (
+	"log/slog"
+
+	"github.com/festus/microkit/messaging"
+)
+
+// Discard returns a logger that drops every record. Adapters use it
+// until the caller injects their own logger.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// Message returns the attributes that identify msg in log records.
+// The payload is only included when withPayload is true; otherwise just
+// its size is logged.
+func Message(topic string, msg messaging.Message, withPayload bool) []any {
+	attrs := []any{
+		slog.String("topic", topic),
+		slog.String("message_id", msg.ID),
+		slog.Int("payload_size", len(msg.Payload)),
+	}
+	if withPayload {
+		attrs = append(attrs, slog.String("payload", string(msg.Payload)))
+	}
+	return attrs
+}
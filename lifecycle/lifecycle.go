@@ -0,0 +1,210 @@
+// Package lifecycle coordinates graceful shutdown of consumers, producers,
+// connections and network clients.
+//
+// Components are registered with a Group in dependency order: connections
+// first, then the producers, consumers and clients built on them. On
+// shutdown the Group
+//
+//  1. stops intake and drains in-flight handlers of every Drainer,
+//  2. flushes every Flusher,
+//  3. closes every component in reverse registration order,
+//
+// all within a single deadline, and reports what did not finish.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Drainer is implemented by consumers. Shutdown stops accepting new
+// messages and waits for in-flight handlers to finish or ctx to end.
+type Drainer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Flusher is implemented by producers with publishes awaiting
+// acknowledgement, such as the Kafka and RabbitMQ producers and brokers.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Phase names the shutdown step a component failed in.
+type Phase string
+
+const (
+	PhaseDrain Phase = "drain"
+	PhaseFlush Phase = "flush"
+	PhaseClose Phase = "close"
+)
+
+// ComponentError describes a component that did not shut down cleanly.
+type ComponentError struct {
+	Name  string
+	Phase Phase
+	Err   error
+}
+
+func (e ComponentError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Name, e.Phase, e.Err)
+}
+
+func (e ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownError lists every component that failed or timed out.
+type ShutdownError struct {
+	Components []ComponentError
+}
+
+func (e *ShutdownError) Error() string {
+	msgs := make([]string, len(e.Components))
+	for i, c := range e.Components {
+		msgs[i] = c.Error()
+	}
+	return "lifecycle: shutdown incomplete: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap exposes the component errors to errors.Is and errors.As.
+func (e *ShutdownError) Unwrap() []error {
+	errs := make([]error, len(e.Components))
+	for i, c := range e.Components {
+		errs[i] = c
+	}
+	return errs
+}
+
+// Option configures a Group.
+type Option func(*Group)
+
+// WithTimeout bounds the whole shutdown sequence. Defaults to 30 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(g *Group) {
+		g.timeout = d
+	}
+}
+
+// WithSignals sets the signals Wait listens for. Defaults to SIGINT and
+// SIGTERM.
+func WithSignals(sig ...os.Signal) Option {
+	return func(g *Group) {
+		g.signals = sig
+	}
+}
+
+type component struct {
+	name string
+	c    io.Closer
+}
+
+// Group shuts down registered components in order.
+type Group struct {
+	timeout time.Duration
+	signals []os.Signal
+
+	mu         sync.Mutex
+	components []component
+
+	once sync.Once
+	err  error
+}
+
+func NewGroup(opts ...Option) *Group {
+	g := &Group{
+		timeout: 30 * time.Second,
+		signals: []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Add registers c under name. Register dependencies before the components
+// that use them; they are closed in reverse order.
+func (g *Group) Add(name string, c io.Closer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.components = append(g.components, component{name: name, c: c})
+}
+
+// Wait blocks until ctx is done or one of the configured signals arrives,
+// then runs Shutdown.
+func (g *Group) Wait(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, g.signals...)
+	<-ctx.Done()
+	stop()
+
+	return g.Shutdown(context.Background())
+}
+
+// Shutdown drains, flushes and closes every component. The configured
+// timeout is applied on top of ctx. Only the first call does any work;
+// later calls return the same result.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.once.Do(func() {
+		g.err = g.shutdown(ctx)
+	})
+	return g.err
+}
+
+func (g *Group) shutdown(ctx context.Context) error {
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	g.mu.Lock()
+	components := append([]component(nil), g.components...)
+	g.mu.Unlock()
+
+	var failed []ComponentError
+	record := func(name string, phase Phase, err error) {
+		if err != nil {
+			failed = append(failed, ComponentError{Name: name, Phase: phase, Err: err})
+		}
+	}
+
+	// Stop intake everywhere at once so no consumer keeps pulling work
+	// while another drains.
+	drainErrs := make([]error, len(components))
+	var wg sync.WaitGroup
+	for i, comp := range components {
+		if d, ok := comp.c.(Drainer); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				drainErrs[i] = d.Shutdown(ctx)
+			}()
+		}
+	}
+	wg.Wait()
+	for i, comp := range components {
+		record(comp.name, PhaseDrain, drainErrs[i])
+	}
+
+	for i := len(components) - 1; i >= 0; i-- {
+		if f, ok := components[i].c.(Flusher); ok {
+			record(components[i].name, PhaseFlush, f.Flush(ctx))
+		}
+	}
+
+	// Close runs even after the deadline so connections are released.
+	for i := len(components) - 1; i >= 0; i-- {
+		record(components[i].name, PhaseClose, components[i].c.Close())
+	}
+
+	if len(failed) > 0 {
+		return &ShutdownError{Components: failed}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/adapters/memory"
+	"github.com/festus/microkit/messaging"
+)
+
+type fake struct {
+	name  string
+	log   *[]string
+	mu    *sync.Mutex
+	delay time.Duration
+}
+
+func (f fake) record(event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*f.log = append(*f.log, event+":"+f.name)
+}
+
+func (f fake) Close() error {
+	f.record("close")
+	return nil
+}
+
+type fakeConsumer struct{ fake }
+
+func (f fakeConsumer) Shutdown(ctx context.Context) error {
+	select {
+	case <-time.After(f.delay):
+		f.record("drain")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type fakeProducer struct{ fake }
+
+func (f fakeProducer) Flush(ctx context.Context) error {
+	f.record("flush")
+	return nil
+}
+
+func TestShutdownOrder(t *testing.T) {
+	var log []string
+	var mu sync.Mutex
+	mk := func(name string) fake { return fake{name: name, log: &log, mu: &mu} }
+
+	g := NewGroup()
+	g.Add("conn", mk("conn"))
+	g.Add("producer", fakeProducer{mk("producer")})
+	g.Add("consumer", fakeConsumer{mk("consumer")})
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	want := []string{"drain:consumer", "flush:producer", "close:consumer", "close:producer", "close:conn"}
+	if len(log) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, log)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, log)
+		}
+	}
+}
+
+func TestShutdownReportsUnfinished(t *testing.T) {
+	var log []string
+	var mu sync.Mutex
+
+	g := NewGroup(WithTimeout(20 * time.Millisecond))
+	g.Add("slow", fakeConsumer{fake{name: "slow", log: &log, mu: &mu, delay: time.Second}})
+
+	err := g.Shutdown(context.Background())
+	var se *ShutdownError
+	if !errors.As(err, &se) {
+		t.Fatalf("Expected *ShutdownError, got %v", err)
+	}
+	if len(se.Components) != 1 || se.Components[0].Name != "slow" || se.Components[0].Phase != PhaseDrain {
+		t.Fatalf("Unexpected report: %v", se)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded in chain, got %v", err)
+	}
+	if len(log) != 1 || log[0] != "close:slow" {
+		t.Fatalf("Expected component to be closed after timeout, got %v", log)
+	}
+}
+
+func TestWaitDrainsInFlightHandlers(t *testing.T) {
+	broker := memory.NewBroker(messaging.DefaultConfig())
+
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	ctx := context.Background()
+	broker.Subscribe(ctx, "jobs", func(ctx context.Context, msg messaging.Message) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+		return nil
+	})
+	broker.Publish(ctx, "jobs", messaging.Message{Payload: []byte("x")})
+	<-started
+
+	g := NewGroup(WithTimeout(time.Second))
+	g.Add("broker", broker)
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := g.Wait(waitCtx); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Handler should have finished before Wait returned")
+	}
+}
@@ -5,4 +5,6 @@ import "errors"
 var (
 	ErrPublishFailed   = errors.New("failed to publish message")
 	ErrSubscriptionErr = errors.New("failed to subscribe to topic")
+	ErrUnknownDriver   = errors.New("messaging: unknown driver")
+	ErrBrokerClosed    = errors.New("messaging: broker closed")
 )
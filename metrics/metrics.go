@@ -0,0 +1,69 @@
+// Package metrics defines the hooks microkit adapters call to report
+// publish latency, handler duration, retries, dead-lettering and network
+// request outcomes. Adapters default to Nop; see metrics/prometheus for a
+// Prometheus implementation.
+package metrics
+
+import "time"
+
+// Outcome is the result of a publish, handler invocation or request.
+type Outcome string
+
+const (
+	Success Outcome = "success"
+	Failure Outcome = "failure"
+)
+
+// OutcomeOf returns Failure if err is non-nil and Success otherwise.
+func OutcomeOf(err error) Outcome {
+	if err != nil {
+		return Failure
+	}
+	return Success
+}
+
+// Recorder receives measurements from adapters. System is the messaging
+// backend ("kafka", "rabbitmq", "memory") and protocol the network client
+// ("http", "grpc"). Implementations must be safe for concurrent use.
+type Recorder interface {
+	// ObservePublish records one publish attempt to topic.
+	ObservePublish(system, topic string, outcome Outcome, d time.Duration)
+
+	// ObserveHandle records one handler invocation; attempt starts at 1.
+	ObserveHandle(system, topic string, attempt int, outcome Outcome, d time.Duration)
+
+	// IncRetry records that a message is scheduled for another attempt.
+	IncRetry(system, topic string, attempt int)
+
+	// IncDeadLetter records that a message was sent to a dead-letter queue
+	// or dropped after exhausting its retries.
+	IncDeadLetter(system, topic string)
+
+	// ObserveRequest records one network request. Status is the HTTP status
+	// code or gRPC status code name, or "error" when no response arrived.
+	ObserveRequest(protocol, method, status string, d time.Duration)
+}
+
+// BulkheadRecorder receives bulkhead saturation from the bulkhead package.
+// It is separate from Recorder so existing recorders keep compiling; the
+// Prometheus recorder and Nop implement both.
+type BulkheadRecorder interface {
+	// SetBulkhead records the requests running in and queued for the
+	// bulkhead name.
+	SetBulkhead(name string, inflight, queued int)
+
+	// IncBulkheadRejected records a request the bulkhead name turned away,
+	// with reason "queue_full" or "queue_timeout".
+	IncBulkheadRejected(name, reason string)
+}
+
+// Nop is a Recorder that discards all measurements.
+type Nop struct{}
+
+func (Nop) ObservePublish(system, topic string, outcome Outcome, d time.Duration)             {}
+func (Nop) ObserveHandle(system, topic string, attempt int, outcome Outcome, d time.Duration) {}
+func (Nop) IncRetry(system, topic string, attempt int)                                        {}
+func (Nop) IncDeadLetter(system, topic string)                                                {}
+func (Nop) ObserveRequest(protocol, method, status string, d time.Duration)                   {}
+func (Nop) SetBulkhead(name string, inflight, queued int)                                     {}
+func (Nop) IncBulkheadRejected(name, reason string)                                           {}
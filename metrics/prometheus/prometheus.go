@@ -0,0 +1,248 @@
+// Package prometheus implements metrics.Recorder with Prometheus collectors.
+//
+// Label values are bounded: attempts of MaxAttemptLabel+1 and above
+// collapse into one "N+" value, where N is MaxAttemptLabel+1, unknown HTTP
+// methods become "OTHER", and once a free-form label (topic, gRPC method)
+// has seen MaxLabelValues distinct values, new ones are reported as
+// "other".
+package prometheus
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/festus/microkit/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// MaxAttemptLabel is the highest attempt number reported individually.
+	MaxAttemptLabel = 5
+
+	// DefaultMaxLabelValues bounds distinct topics and gRPC methods.
+	DefaultMaxLabelValues = 100
+
+	otherLabel = "other"
+)
+
+// Option configures a Recorder.
+type Option func(*config)
+
+type config struct {
+	namespace      string
+	buckets        []float64
+	maxLabelValues int
+}
+
+// WithNamespace sets the metric name prefix. Defaults to "microkit".
+func WithNamespace(ns string) Option {
+	return func(c *config) {
+		c.namespace = ns
+	}
+}
+
+// WithBuckets sets the histogram buckets, in seconds.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// WithMaxLabelValues sets how many distinct topics and gRPC methods are
+// tracked before new values are reported as "other".
+func WithMaxLabelValues(n int) Option {
+	return func(c *config) {
+		c.maxLabelValues = n
+	}
+}
+
+// Recorder reports microkit measurements to Prometheus.
+type Recorder struct {
+	publishDuration *prom.HistogramVec
+	handleDuration  *prom.HistogramVec
+	retries         *prom.CounterVec
+	deadLetters     *prom.CounterVec
+	requestDuration *prom.HistogramVec
+	bulkheadFlight  *prom.GaugeVec
+	bulkheadQueued  *prom.GaugeVec
+	bulkheadRejects *prom.CounterVec
+
+	topics  *limiter
+	methods *limiter
+}
+
+var (
+	_ metrics.Recorder         = (*Recorder)(nil)
+	_ metrics.BulkheadRecorder = (*Recorder)(nil)
+)
+
+// New creates a Recorder and registers its collectors with reg.
+func New(reg prom.Registerer, opts ...Option) (*Recorder, error) {
+	cfg := config{
+		namespace:      "microkit",
+		buckets:        prom.DefBuckets,
+		maxLabelValues: DefaultMaxLabelValues,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &Recorder{
+		publishDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "messaging",
+			Name:      "publish_duration_seconds",
+			Help:      "Time taken to publish a message.",
+			Buckets:   cfg.buckets,
+		}, []string{"system", "topic", "outcome"}),
+		handleDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "messaging",
+			Name:      "handle_duration_seconds",
+			Help:      "Time taken by message handlers.",
+			Buckets:   cfg.buckets,
+		}, []string{"system", "topic", "outcome", "attempt"}),
+		retries: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "messaging",
+			Name:      "retries_total",
+			Help:      "Messages scheduled for another handler attempt.",
+		}, []string{"system", "topic", "attempt"}),
+		deadLetters: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "messaging",
+			Name:      "dead_letters_total",
+			Help:      "Messages dead-lettered after exhausting retries.",
+		}, []string{"system", "topic"}),
+		requestDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "network",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken by outbound HTTP and gRPC requests.",
+			Buckets:   cfg.buckets,
+		}, []string{"protocol", "method", "status"}),
+		bulkheadFlight: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "bulkhead",
+			Name:      "in_flight",
+			Help:      "Requests running inside a bulkhead.",
+		}, []string{"name"}),
+		bulkheadQueued: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "bulkhead",
+			Name:      "queued",
+			Help:      "Requests waiting for a bulkhead slot.",
+		}, []string{"name"}),
+		bulkheadRejects: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "bulkhead",
+			Name:      "rejected_total",
+			Help:      "Requests turned away by a bulkhead.",
+		}, []string{"name", "reason"}),
+		topics:  newLimiter(cfg.maxLabelValues),
+		methods: newLimiter(cfg.maxLabelValues),
+	}
+
+	for _, c := range []prom.Collector{
+		r.publishDuration, r.handleDuration, r.retries, r.deadLetters, r.requestDuration,
+		r.bulkheadFlight, r.bulkheadQueued, r.bulkheadRejects,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *Recorder) ObservePublish(system, topic string, outcome metrics.Outcome, d time.Duration) {
+	r.publishDuration.WithLabelValues(system, r.topics.value(topic), string(outcome)).Observe(d.Seconds())
+}
+
+func (r *Recorder) ObserveHandle(system, topic string, attempt int, outcome metrics.Outcome, d time.Duration) {
+	r.handleDuration.WithLabelValues(system, r.topics.value(topic), string(outcome), attemptLabel(attempt)).Observe(d.Seconds())
+}
+
+func (r *Recorder) IncRetry(system, topic string, attempt int) {
+	r.retries.WithLabelValues(system, r.topics.value(topic), attemptLabel(attempt)).Inc()
+}
+
+func (r *Recorder) IncDeadLetter(system, topic string) {
+	r.deadLetters.WithLabelValues(system, r.topics.value(topic)).Inc()
+}
+
+func (r *Recorder) ObserveRequest(protocol, method, status string, d time.Duration) {
+	if protocol == "http" {
+		method = httpMethodLabel(method)
+		status = httpStatusLabel(status)
+	} else {
+		method = r.methods.value(method)
+	}
+	r.requestDuration.WithLabelValues(protocol, method, status).Observe(d.Seconds())
+}
+
+func (r *Recorder) SetBulkhead(name string, inflight, queued int) {
+	r.bulkheadFlight.WithLabelValues(name).Set(float64(inflight))
+	r.bulkheadQueued.WithLabelValues(name).Set(float64(queued))
+}
+
+func (r *Recorder) IncBulkheadRejected(name, reason string) {
+	r.bulkheadRejects.WithLabelValues(name, reason).Inc()
+}
+
+func attemptLabel(attempt int) string {
+	if attempt > MaxAttemptLabel {
+		return strconv.Itoa(MaxAttemptLabel+1) + "+"
+	}
+	return strconv.Itoa(attempt)
+}
+
+func httpMethodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+func httpStatusLabel(status string) string {
+	if code, err := strconv.Atoi(status); err == nil && (code < 100 || code > 599) {
+		return otherLabel
+	}
+	return status
+}
+
+// limiter passes through the first max distinct values it sees and maps
+// every later one to "other".
+type limiter struct {
+	max int
+
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+func newLimiter(max int) *limiter {
+	return &limiter{max: max, seen: make(map[string]struct{})}
+}
+
+func (l *limiter) value(v string) string {
+	l.mu.RLock()
+	_, ok := l.seen[v]
+	l.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[v]; ok {
+		return v
+	}
+	if len(l.seen) >= l.max {
+		return otherLabel
+	}
+	l.seen[v] = struct{}{}
+	return v
+}
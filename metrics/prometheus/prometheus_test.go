@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/adapters/memory"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMessagingMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	rec, err := New(reg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	broker := memory.NewBroker(messaging.Config{RetryCount: 1, RetryDelay: time.Millisecond}, memory.WithMetrics(rec))
+
+	broker.Subscribe(ctx, "orders", func(ctx context.Context, msg messaging.Message) error {
+		return errors.New("downstream unavailable")
+	})
+	broker.Publish(ctx, "orders", messaging.Message{Payload: []byte("x")})
+
+	time.Sleep(100 * time.Millisecond)
+	broker.Close()
+
+	if got := testutil.ToFloat64(rec.retries.WithLabelValues("memory", "orders", "2")); got != 1 {
+		t.Fatalf("Expected 1 retry, got %v", got)
+	}
+	if got := testutil.ToFloat64(rec.deadLetters.WithLabelValues("memory", "orders")); got != 1 {
+		t.Fatalf("Expected 1 dead letter, got %v", got)
+	}
+	if got := testutil.CollectAndCount(rec.handleDuration); got != 2 {
+		t.Fatalf("Expected 2 handle series (attempt 1 and 2), got %d", got)
+	}
+}
+
+func TestLabelCardinalityIsBounded(t *testing.T) {
+	rec, err := New(prom.NewRegistry(), WithMaxLabelValues(3))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		rec.ObservePublish("kafka", "topic-"+strconv.Itoa(i), metrics.Success, time.Millisecond)
+		rec.ObserveRequest("grpc", "/svc.S/M"+strconv.Itoa(i), "OK", time.Millisecond)
+		rec.ObserveRequest("http", "BREW", "999", time.Millisecond)
+		rec.IncRetry("kafka", "topic-0", i+1)
+	}
+
+	if got := testutil.CollectAndCount(rec.publishDuration); got != 4 {
+		t.Fatalf("Expected 3 topics plus other, got %d series", got)
+	}
+	if got := testutil.CollectAndCount(rec.requestDuration); got != 5 {
+		t.Fatalf("Expected 3 methods, other, and one bounded HTTP series, got %d", got)
+	}
+	if got := testutil.CollectAndCount(rec.retries); got != MaxAttemptLabel+1 {
+		t.Fatalf("Expected attempts to collapse to %d series, got %d", MaxAttemptLabel+1, got)
+	}
+	if got := testutil.ToFloat64(rec.retries.WithLabelValues("kafka", "topic-0", strconv.Itoa(MaxAttemptLabel))); got != 1 {
+		t.Fatalf("Expected attempt %d to be reported individually, got %v", MaxAttemptLabel, got)
+	}
+	if got := testutil.ToFloat64(rec.retries.WithLabelValues("kafka", "topic-0", "6+")); got != 5 {
+		t.Fatalf("Expected attempts 6 to 10 under 6+, got %v", got)
+	}
+}
+
+func TestBulkheadMetrics(t *testing.T) {
+	rec, err := New(prom.NewRegistry())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	rec.SetBulkhead("orders", 3, 2)
+	rec.IncBulkheadRejected("orders", "queue_full")
+
+	if got := testutil.ToFloat64(rec.bulkheadFlight.WithLabelValues("orders")); got != 3 {
+		t.Fatalf("Expected 3 in flight, got %v", got)
+	}
+	if got := testutil.ToFloat64(rec.bulkheadQueued.WithLabelValues("orders")); got != 2 {
+		t.Fatalf("Expected 2 queued, got %v", got)
+	}
+	if got := testutil.ToFloat64(rec.bulkheadRejects.WithLabelValues("orders", "queue_full")); got != 1 {
+		t.Fatalf("Expected 1 rejection, got %v", got)
+	}
+}
@@ -2,16 +2,34 @@ package network
 
 import "context"
 
-// Client defines the interface for making network calls (HTTP/gRPC).
-type Client interface {
-	// HTTP methods
+// HTTPClient makes HTTP requests.
+type HTTPClient interface {
 	Get(ctx context.Context, url string, opts ...Option) (*Response, error)
+	Head(ctx context.Context, url string, opts ...Option) (*Response, error)
+	Options(ctx context.Context, url string, opts ...Option) (*Response, error)
 	Post(ctx context.Context, url string, body []byte, opts ...Option) (*Response, error)
 	Put(ctx context.Context, url string, body []byte, opts ...Option) (*Response, error)
+	Patch(ctx context.Context, url string, body []byte, opts ...Option) (*Response, error)
 	Delete(ctx context.Context, url string, opts ...Option) (*Response, error)
-	
-	// gRPC method
-	Call(ctx context.Context, method string, req interface{}, resp interface{}, opts ...Option) error
-	
+
+	// Do sends req with any method.
+	Do(ctx context.Context, req Request, opts ...Option) (*Response, error)
+
+	Close() error
+}
+
+// RPCClient makes unary RPCs.
+type RPCClient interface {
+	Call(ctx context.Context, method string, req any, resp any, opts ...Option) error
+
 	Close() error
-}
\ No newline at end of file
+}
+
+// Client defines the interface for making network calls (HTTP/gRPC).
+// Adapters implement all of it, returning ErrUnsupportedOperation from the
+// methods of the protocol they do not speak; depend on HTTPClient or
+// RPCClient to accept only clients that support what you call.
+type Client interface {
+	HTTPClient
+	RPCClient
+}
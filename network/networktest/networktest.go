@@ -0,0 +1,124 @@
+// Package networktest checks that network.Client implementations behave
+// alike. Each adapter runs the suites matching what it supports from its
+// own tests:
+//
+//	var _ network.Client = (*Client)(nil)
+//
+//	func TestConformance(t *testing.T) {
+//		networktest.HTTP(t, NewClient(time.Second))
+//		networktest.UnsupportedRPC(t, NewClient(time.Second))
+//	}
+package networktest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/festus/microkit/network"
+)
+
+// HTTP checks every method of c against a local server that echoes the
+// request method and body.
+func HTTP(t *testing.T, c network.HTTPClient) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	body := []byte("payload")
+	calls := []struct {
+		method string
+		body   []byte
+		call   func() (*network.Response, error)
+	}{
+		{http.MethodGet, nil, func() (*network.Response, error) { return c.Get(ctx, srv.URL) }},
+		{http.MethodHead, nil, func() (*network.Response, error) { return c.Head(ctx, srv.URL) }},
+		{http.MethodOptions, nil, func() (*network.Response, error) { return c.Options(ctx, srv.URL) }},
+		{http.MethodPost, body, func() (*network.Response, error) { return c.Post(ctx, srv.URL, body) }},
+		{http.MethodPut, body, func() (*network.Response, error) { return c.Put(ctx, srv.URL, body) }},
+		{http.MethodPatch, body, func() (*network.Response, error) { return c.Patch(ctx, srv.URL, body) }},
+		{http.MethodDelete, nil, func() (*network.Response, error) { return c.Delete(ctx, srv.URL) }},
+		{"PROPFIND", body, func() (*network.Response, error) {
+			return c.Do(ctx, network.Request{Method: "PROPFIND", URL: srv.URL, Body: body})
+		}},
+	}
+	for _, tc := range calls {
+		resp, err := tc.call()
+		if err != nil {
+			t.Errorf("%s failed: %v", tc.method, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tc.method, resp.StatusCode)
+		}
+		if got := resp.Headers.Get("X-Method"); got != tc.method {
+			t.Errorf("%s: server saw method %q", tc.method, got)
+		}
+		if string(resp.Body) != string(tc.body) {
+			t.Errorf("%s: expected body %q, got %q", tc.method, tc.body, resp.Body)
+		}
+	}
+}
+
+// RPC checks that c completes a call of method with req and resp, and
+// fails it once the context is cancelled.
+func RPC(t *testing.T, c network.RPCClient, method string, req, resp any) {
+	t.Helper()
+	if err := c.Call(context.Background(), method, req, resp); err != nil {
+		t.Errorf("Call failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Call(ctx, method, req, resp); err == nil {
+		t.Error("Call: expected an error with a cancelled context")
+	}
+}
+
+// UnsupportedHTTP checks that every HTTP method of c returns
+// network.ErrUnsupportedOperation rather than panicking.
+func UnsupportedHTTP(t *testing.T, c network.HTTPClient) {
+	t.Helper()
+	ctx := context.Background()
+	const url = "http://localhost"
+	calls := map[string]func() (*network.Response, error){
+		"Get":     func() (*network.Response, error) { return c.Get(ctx, url) },
+		"Head":    func() (*network.Response, error) { return c.Head(ctx, url) },
+		"Options": func() (*network.Response, error) { return c.Options(ctx, url) },
+		"Post":    func() (*network.Response, error) { return c.Post(ctx, url, nil) },
+		"Put":     func() (*network.Response, error) { return c.Put(ctx, url, nil) },
+		"Patch":   func() (*network.Response, error) { return c.Patch(ctx, url, nil) },
+		"Delete":  func() (*network.Response, error) { return c.Delete(ctx, url) },
+		"Do":      func() (*network.Response, error) { return c.Do(ctx, network.Request{Method: "GET", URL: url}) },
+	}
+	for name, call := range calls {
+		resp, err := call()
+		if !errors.Is(err, network.ErrUnsupportedOperation) {
+			t.Errorf("%s: expected ErrUnsupportedOperation, got %v", name, err)
+		}
+		if resp != nil {
+			t.Errorf("%s: expected no response, got %+v", name, resp)
+		}
+	}
+}
+
+// UnsupportedRPC checks that Call on c returns
+// network.ErrUnsupportedOperation rather than panicking.
+func UnsupportedRPC(t *testing.T, c network.RPCClient) {
+	t.Helper()
+	err := c.Call(context.Background(), "/pkg.Service/Method", nil, nil)
+	if !errors.Is(err, network.ErrUnsupportedOperation) {
+		t.Errorf("Call: expected ErrUnsupportedOperation, got %v", err)
+	}
+}
@@ -3,7 +3,11 @@ package network
 import (
 	"time"
 
-	"github.com/festus/microkit/internal/retry"
+	"github.com/festus/microkit/breaker"
+	"github.com/festus/microkit/bulkhead"
+	"github.com/festus/microkit/hedge"
+	"github.com/festus/microkit/ratelimit"
+	"github.com/festus/microkit/retry"
 )
 
 // Option configures network requests.
@@ -11,19 +15,41 @@ type Option func(*Config)
 
 // Config holds configuration for network requests.
 type Config struct {
-	Headers          map[string]string
+	Headers          Header
 	Timeout          time.Duration
-	RetryConfig      *retry.Config
+	Retry            retry.Strategy
+	RetryOptions     []retry.Option
 	RetryStatusCodes []int
+	Breakers         *breaker.Set
+	Hedger           *hedge.Hedger
+	RateLimiter      *ratelimit.Limiter
+	Concurrency      *ratelimit.Adaptive
+	Bulkhead         *bulkhead.Bulkhead
+	StatusErrors     bool
+	ResponseHeaders  *Header
+	ResponseTrailers *Header
 }
 
-// WithHeader adds a header to the request.
+// WithHeader sets a header on the request, replacing earlier values.
 func WithHeader(key, value string) Option {
 	return func(c *Config) {
 		if c.Headers == nil {
-			c.Headers = make(map[string]string)
+			c.Headers = make(Header)
+		}
+		c.Headers.Set(key, value)
+	}
+}
+
+// WithHeaderValues adds values to a header, keeping earlier ones, for
+// headers that may repeat.
+func WithHeaderValues(key string, values ...string) Option {
+	return func(c *Config) {
+		if c.Headers == nil {
+			c.Headers = make(Header)
+		}
+		for _, v := range values {
+			c.Headers.Add(key, v)
 		}
-		c.Headers[key] = value
 	}
 }
 
@@ -35,29 +61,118 @@ func WithTimeout(timeout time.Duration) Option {
 }
 
 // WithRetry enables retry logic for network calls.
-// Set jitter to true to add randomization and prevent thundering herd.
+// Delays grow exponentially with jitter to prevent thundering herd.
 func WithRetry(maxAttempts int, initialDelay, maxDelay time.Duration, multiplier float64) Option {
 	return func(c *Config) {
-		c.RetryConfig = &retry.Config{
+		c.Retry = retry.Config{
 			MaxAttempts:  maxAttempts,
 			InitialDelay: initialDelay,
 			MaxDelay:     maxDelay,
 			Multiplier:   multiplier,
 			Jitter:       true,
 		}
+		c.RetryOptions = []retry.Option{retry.WithMaxDelay(maxDelay)}
+	}
+}
+
+// WithRetryStrategy enables retry logic driven by a custom strategy, such
+// as a retry.Policy with Fibonacci or decorrelated-jitter backoff.
+func WithRetryStrategy(s retry.Strategy, opts ...retry.Option) Option {
+	return func(c *Config) {
+		c.Retry = s
+		c.RetryOptions = opts
 	}
 }
 
-// WithRetryOnStatus enables retry based on HTTP status codes.
+// WithRetryOnStatus enables retry based on HTTP status codes. A
+// Retry-After header on such a response sets the next delay, capped by
+// maxDelay.
 func WithRetryOnStatus(maxAttempts int, initialDelay, maxDelay time.Duration, multiplier float64, statusCodes ...int) Option {
 	return func(c *Config) {
-		c.RetryConfig = &retry.Config{
+		c.Retry = retry.Config{
 			MaxAttempts:  maxAttempts,
 			InitialDelay: initialDelay,
 			MaxDelay:     maxDelay,
 			Multiplier:   multiplier,
 			Jitter:       true,
 		}
+		c.RetryOptions = []retry.Option{retry.WithMaxDelay(maxDelay)}
 		c.RetryStatusCodes = statusCodes
 	}
-}
\ No newline at end of file
+}
+
+// WithCircuitBreaker guards requests with circuit breakers from set, one
+// per host for HTTP and one per method for gRPC. Requests to an open
+// circuit fail immediately with breaker.ErrCircuitOpen and are not retried.
+func WithCircuitBreaker(set *breaker.Set) Option {
+	return func(c *Config) {
+		c.Breakers = set
+	}
+}
+
+// WithHedging sends up to maxHedges extra copies of a request, one every
+// delay, while none has answered, and takes the first success. Use it for
+// idempotent calls only; HTTP clients hedge only idempotent methods. For a
+// delay derived from observed latency or a hedging budget, share a
+// hedge.Hedger with WithHedger instead.
+func WithHedging(delay time.Duration, maxHedges int) Option {
+	return func(c *Config) {
+		c.Hedger = hedge.New(hedge.Config{Delay: delay, MaxHedges: maxHedges})
+	}
+}
+
+// WithHedger hedges requests as decided by h, which keeps the observed
+// latencies and budget across every call it is passed to.
+func WithHedger(h *hedge.Hedger) Option {
+	return func(c *Config) {
+		c.Hedger = h
+	}
+}
+
+// WithRateLimit takes a token from l before every attempt, keyed by host
+// for HTTP and method for gRPC unless ratelimit.WithKey sets a route key.
+// Rejected attempts fail with ratelimit.ErrRateLimited and are not retried.
+func WithRateLimit(l *ratelimit.Limiter) Option {
+	return func(c *Config) {
+		c.RateLimiter = l
+	}
+}
+
+// WithConcurrencyLimit caps the attempts in flight with l, which adapts its
+// limit to their latency and overload errors. Attempts over the limit fail
+// with ratelimit.ErrLimitExceeded and are not retried.
+func WithConcurrencyLimit(l *ratelimit.Adaptive) Option {
+	return func(c *Config) {
+		c.Concurrency = l
+	}
+}
+
+// WithBulkhead runs every attempt, retries and hedges included, in a slot
+// of b, the bulkhead of the dependency being called. HTTP attempts hold
+// their slot until the response body is closed. Rejected attempts fail
+// with a *bulkhead.RejectedError and are not retried.
+func WithBulkhead(b *bulkhead.Bulkhead) Option {
+	return func(c *Config) {
+		c.Bulkhead = b
+	}
+}
+
+// WithResponseMetadata stores the response headers and trailers of the
+// final attempt in headers and trailers, either of which may be nil. gRPC
+// clients fill both from the response metadata. HTTP clients fill both
+// too, though Response.Headers already carries the headers; streamed
+// trailers are complete once the body has been read to the end.
+func WithResponseMetadata(headers, trailers *Header) Option {
+	return func(c *Config) {
+		c.ResponseHeaders = headers
+		c.ResponseTrailers = trailers
+	}
+}
+
+// WithStatusErrors makes HTTP clients return an error describing the
+// response, alongside the response itself, when the status is not 2xx.
+func WithStatusErrors() Option {
+	return func(c *Config) {
+		c.StatusErrors = true
+	}
+}
@@ -1,23 +1,82 @@
 package network
 
-import "errors"
+import (
+	"errors"
+	"net/textproto"
+	"time"
+)
 
 // Response represents a network response.
 type Response struct {
 	StatusCode int
-	Headers    map[string]string
+	Headers    Header
 	Body       []byte
+
+	// Proto is the protocol version, such as "HTTP/1.1" or "HTTP/2.0".
+	Proto string
+
+	// ContentLength is the length the server declared for the body, or
+	// -1 if it did not declare one.
+	ContentLength int64
+
+	// URL is the URL that produced the response, after any redirects.
+	URL string
+
+	// Elapsed is the time from sending the request to reading the whole
+	// response, retries included.
+	Elapsed time.Duration
 }
 
 // Request represents a network request.
 type Request struct {
 	Method  string
 	URL     string
-	Headers map[string]string
+	Headers Header
 	Body    []byte
 }
 
+// Header holds multi-value headers. It has the same layout as http.Header,
+// so the two convert directly: http.Header(h) and network.Header(h).
+type Header map[string][]string
+
+// Add appends value to key's values.
+func (h Header) Add(key, value string) {
+	textproto.MIMEHeader(h).Add(key, value)
+}
+
+// Set replaces key's values with value.
+func (h Header) Set(key, value string) {
+	textproto.MIMEHeader(h).Set(key, value)
+}
+
+// Get returns the first value for key, or "" if there is none.
+func (h Header) Get(key string) string {
+	return textproto.MIMEHeader(h).Get(key)
+}
+
+// Values returns all values for key.
+func (h Header) Values(key string) []string {
+	return textproto.MIMEHeader(h).Values(key)
+}
+
+// Del removes key.
+func (h Header) Del(key string) {
+	textproto.MIMEHeader(h).Del(key)
+}
+
+// Clone returns a deep copy of h, or nil if h is nil.
+func (h Header) Clone() Header {
+	if h == nil {
+		return nil
+	}
+	c := make(Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
 // Common errors
 var (
 	ErrUnsupportedOperation = errors.New("operation not supported by this client")
-)
\ No newline at end of file
+)
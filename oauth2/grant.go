@@ -0,0 +1,181 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuthStyle is how the client authenticates to the token endpoint.
+type AuthStyle int
+
+const (
+	// AuthHeader sends the client ID and secret with HTTP Basic
+	// authentication (client_secret_basic).
+	AuthHeader AuthStyle = iota
+	// AuthParams sends them as form parameters (client_secret_post).
+	AuthParams
+)
+
+// Config describes the client and the token endpoint shared by all grants.
+type Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// Params are extra form parameters sent with every token request,
+	// such as audience or resource.
+	Params url.Values
+
+	AuthStyle AuthStyle
+
+	// HTTPClient sends token requests. Defaults to a client with a 30
+	// second timeout.
+	HTTPClient *http.Client
+}
+
+// Error is an error response from the token endpoint.
+type Error struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: token endpoint: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2: token endpoint: %s (status %d)", e.Code, e.StatusCode)
+}
+
+// ClientCredentials obtains tokens for the client itself with the
+// client_credentials grant.
+func ClientCredentials(cfg Config) *Source {
+	return NewSource(func(ctx context.Context) (*Token, error) {
+		return cfg.exchange(ctx, url.Values{"grant_type": {"client_credentials"}})
+	})
+}
+
+// JWTBearer obtains tokens by presenting a signed JWT from assertion with
+// the JWT bearer grant (RFC 7523). The client ID and secret are optional
+// with this grant; use JWT.Assertion to sign assertions locally.
+func JWTBearer(cfg Config, assertion func(ctx context.Context) (string, error)) *Source {
+	return NewSource(func(ctx context.Context) (*Token, error) {
+		jwt, err := assertion(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2: assertion: %w", err)
+		}
+		return cfg.exchange(ctx, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {jwt},
+		})
+	})
+}
+
+// RefreshToken obtains tokens with the refresh_token grant, starting from
+// refreshToken. When the server rotates the refresh token, the new one is
+// used from then on.
+func RefreshToken(cfg Config, refreshToken string) *Source {
+	// The Source never calls fetch concurrently, so refreshToken needs no
+	// lock of its own.
+	return NewSource(func(ctx context.Context) (*Token, error) {
+		token, err := cfg.exchange(ctx, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if token.RefreshToken != "" {
+			refreshToken = token.RefreshToken
+		}
+		return token, nil
+	})
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// exchange posts a token request with form and decodes the response.
+func (cfg Config) exchange(ctx context.Context, form url.Values) (*Token, error) {
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	for k, vs := range cfg.Params {
+		form[k] = vs
+	}
+	if cfg.ClientID != "" && cfg.AuthStyle == AuthParams {
+		form.Set("client_id", cfg.ClientID)
+		if cfg.ClientSecret != "" {
+			form.Set("client_secret", cfg.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientID != "" && cfg.AuthStyle == AuthHeader {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: token response: %w", err)
+	}
+
+	var tr struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		RefreshToken     string      `json:"refresh_token"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		ErrorCode        string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	jsonErr := json.Unmarshal(body, &tr)
+	if resp.StatusCode != http.StatusOK || tr.ErrorCode != "" {
+		e := &Error{StatusCode: resp.StatusCode, Code: tr.ErrorCode, Description: tr.ErrorDescription}
+		if e.Code == "" {
+			e.Code = http.StatusText(resp.StatusCode)
+		}
+		return nil, e
+	}
+	if jsonErr != nil {
+		return nil, fmt.Errorf("oauth2: token response: %w", jsonErr)
+	}
+
+	token := &Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType, RefreshToken: tr.RefreshToken}
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	if tr.ExpiresIn != "" {
+		secs, err := strconv.ParseInt(string(tr.ExpiresIn), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2: token response: bad expires_in %q", tr.ExpiresIn)
+		}
+		if secs > 0 {
+			// Counting from when the request was sent errs on the side of
+			// refreshing early.
+			token.Expiry = start.Add(time.Duration(secs) * time.Second)
+		}
+	}
+	return token, nil
+}
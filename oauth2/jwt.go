@@ -0,0 +1,114 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWT signs assertions for the JWT bearer grant.
+type JWT struct {
+	Issuer   string
+	Subject  string
+	Audience string // usually the token URL
+
+	// KeyID, if set, is sent as the kid header so the server can pick the
+	// right key during rotation.
+	KeyID string
+
+	// Key signs the assertion: an RSA key signs with RS256, a P-256 ECDSA
+	// key with ES256. Any crypto.Signer for those keys works, including
+	// ones backed by a KMS or HSM.
+	Key crypto.Signer
+
+	// Lifetime is how long each assertion is valid. Defaults to 5 minutes.
+	Lifetime time.Duration
+
+	// Claims are extra claims added to every assertion.
+	Claims map[string]any
+}
+
+// Assertion returns a newly signed assertion. It has the signature JWTBearer
+// expects.
+func (j JWT) Assertion(context.Context) (string, error) {
+	var alg string
+	switch pub := j.Key.Public().(type) {
+	case *rsa.PublicKey:
+		alg = "RS256"
+	case *ecdsa.PublicKey:
+		if pub.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("oauth2: unsupported ECDSA curve %s", pub.Curve.Params().Name)
+		}
+		alg = "ES256"
+	default:
+		return "", fmt.Errorf("oauth2: unsupported key type %T", pub)
+	}
+
+	lifetime := j.Lifetime
+	if lifetime <= 0 {
+		lifetime = 5 * time.Minute
+	}
+	var jti [16]byte
+	rand.Read(jti[:])
+	now := time.Now()
+	claims := map[string]any{}
+	for k, v := range j.Claims {
+		claims[k] = v
+	}
+	claims["iss"] = j.Issuer
+	claims["sub"] = j.Subject
+	claims["aud"] = j.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(lifetime).Unix()
+	claims["jti"] = hex.EncodeToString(jti[:])
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if j.KeyID != "" {
+		header["kid"] = j.KeyID
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("oauth2: claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := j.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("oauth2: sign assertion: %w", err)
+	}
+	if alg == "ES256" {
+		if sig, err = rawECDSA(sig); err != nil {
+			return "", err
+		}
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// rawECDSA converts an ASN.1 ECDSA P-256 signature into the fixed-size
+// r || s form JWS requires.
+func rawECDSA(der []byte) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("oauth2: sign assertion: %w", err)
+	}
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
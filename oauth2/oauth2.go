@@ -0,0 +1,159 @@
+// Package oauth2 obtains OAuth2 access tokens for outbound calls.
+//
+// Each grant (ClientCredentials, JWTBearer, RefreshToken) returns a
+// *Source that caches its token until shortly before it expires and
+// refreshes it once for all concurrent callers. Hand the Source to
+// microhttp.WithOAuth2 or grpc.WithOAuth2, which also refresh the token
+// and retry once when a server rejects it.
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// refreshMargin is how long before expiry a token is renewed. Tokens that
+// live less than twice as long are renewed halfway through instead.
+const refreshMargin = time.Minute
+
+// failureBackoff is how long a cached token is used after a failed
+// renewal before the next one is tried.
+const failureBackoff = 5 * time.Second
+
+// Token is an access token. Tokens are shared between callers and must
+// not be modified.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+
+	// Expiry is when the token expires, or zero if the server did not
+	// say. Tokens without an expiry are used until a server rejects them.
+	Expiry time.Time
+}
+
+// TokenSource hands out access tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// Refresher is a TokenSource that can be made to replace a token a server
+// has rejected.
+type Refresher interface {
+	TokenSource
+
+	// Refresh fetches a new token if stale is still the current one, and
+	// otherwise returns the token that already replaced it.
+	Refresh(ctx context.Context, stale *Token) (*Token, error)
+}
+
+// FetchFunc obtains a new token from the authorization server.
+type FetchFunc func(ctx context.Context) (*Token, error)
+
+// Source caches the tokens returned by a FetchFunc. It is safe for
+// concurrent use.
+type Source struct {
+	fetch FetchFunc
+	now   func() time.Time
+
+	mu        sync.Mutex
+	token     *Token
+	refreshAt time.Time
+	pending   *fetchResult
+}
+
+// fetchResult is the outcome of a fetch in progress, shared by every
+// caller waiting for it.
+type fetchResult struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+var _ Refresher = (*Source)(nil)
+
+func NewSource(fetch FetchFunc) *Source {
+	return &Source{fetch: fetch, now: time.Now}
+}
+
+// Token returns the cached token, fetching a new one if there is none or
+// it is about to expire. If fetching fails while the cached token has not
+// yet expired, the cached token is returned and the next fetch waits a
+// few seconds.
+func (s *Source) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	if s.token != nil && (s.refreshAt.IsZero() || s.now().Before(s.refreshAt)) {
+		defer s.mu.Unlock()
+		return s.token, nil
+	}
+	return s.renew(ctx)
+}
+
+func (s *Source) Refresh(ctx context.Context, stale *Token) (*Token, error) {
+	s.mu.Lock()
+	if s.token != nil && s.token != stale {
+		defer s.mu.Unlock()
+		return s.token, nil
+	}
+	// The server rejected stale, so it must not be handed out again.
+	s.token = nil
+	return s.renew(ctx)
+}
+
+// renew waits for the fetch in progress, starting one if there is none,
+// and unlocks s.mu, which must be held. Callers stop waiting when their
+// ctx ends; the fetch itself runs on until the FetchFunc returns, so
+// that other callers can still use its token.
+func (s *Source) renew(ctx context.Context) (*Token, error) {
+	r := s.pending
+	if r == nil {
+		r = &fetchResult{done: make(chan struct{})}
+		s.pending = r
+		go s.run(context.WithoutCancel(ctx), r)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.token, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run fetches a token, caches it and hands it to the callers waiting on r.
+func (s *Source) run(ctx context.Context, r *fetchResult) {
+	token, err := s.fetch(ctx)
+	if err == nil && (token == nil || token.AccessToken == "") {
+		err = fmt.Errorf("oauth2: empty access token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer close(r.done)
+	s.pending = nil
+
+	now := s.now()
+	if err != nil {
+		if s.token != nil && now.Before(s.token.Expiry) {
+			s.refreshAt = now.Add(failureBackoff)
+			if s.refreshAt.After(s.token.Expiry) {
+				s.refreshAt = s.token.Expiry
+			}
+			r.token = s.token
+			return
+		}
+		r.err = err
+		return
+	}
+
+	s.token = token
+	s.refreshAt = time.Time{}
+	if !token.Expiry.IsZero() {
+		margin := min(refreshMargin, token.Expiry.Sub(now)/2)
+		s.refreshAt = token.Expiry.Add(-margin)
+	}
+	r.token = token
+}
@@ -0,0 +1,286 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer is a token endpoint that issues "token-1", "token-2", ...
+type tokenServer struct {
+	*httptest.Server
+	issued    atomic.Int32
+	expiresIn int
+
+	mu    sync.Mutex
+	forms []map[string]string
+	auth  []string
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+	ts := &tokenServer{expiresIn: expiresIn}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		ts.mu.Lock()
+		ts.forms = append(ts.forms, form)
+		ts.auth = append(ts.auth, r.Header.Get("Authorization"))
+		ts.mu.Unlock()
+
+		if form["refresh_token"] == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"refresh token revoked"}`))
+			return
+		}
+		n := ts.issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d,"refresh_token":"refresh-%d"}`, n, ts.expiresIn, n)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tokenServer) lastForm() map[string]string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.forms[len(ts.forms)-1]
+}
+
+func TestClientCredentialsCachesSingleFlight(t *testing.T) {
+	srv := newTokenServer(t, 3600)
+	src := ClientCredentials(Config{
+		TokenURL:     srv.URL,
+		ClientID:     "orders",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := src.Token(context.Background())
+			if err != nil || token.AccessToken != "token-1" {
+				t.Errorf("Expected token-1, got %v, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := srv.issued.Load(); got != 1 {
+		t.Fatalf("Expected 1 token request, got %d", got)
+	}
+	form := srv.lastForm()
+	if form["grant_type"] != "client_credentials" || form["scope"] != "read write" {
+		t.Fatalf("Unexpected form %v", form)
+	}
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("orders:s3cret")); srv.auth[0] != want {
+		t.Fatalf("Expected basic client authentication, got %q", srv.auth[0])
+	}
+}
+
+func TestSourceRefreshesBeforeExpiry(t *testing.T) {
+	srv := newTokenServer(t, 3600)
+	src := ClientCredentials(Config{TokenURL: srv.URL, ClientID: "orders", AuthStyle: AuthParams})
+	now := time.Now()
+	src.now = func() time.Time { return now }
+
+	if token, _ := src.Token(context.Background()); token.AccessToken != "token-1" || token.TokenType != "bearer" {
+		t.Fatalf("Unexpected token %+v", token)
+	}
+	if form := srv.lastForm(); form["client_id"] != "orders" {
+		t.Fatalf("Expected the client ID in the form, got %v", form)
+	}
+
+	now = now.Add(58 * time.Minute)
+	if token, _ := src.Token(context.Background()); token.AccessToken != "token-1" {
+		t.Fatalf("Expected the cached token, got %s", token.AccessToken)
+	}
+	now = now.Add(90 * time.Second)
+	if token, _ := src.Token(context.Background()); token.AccessToken != "token-2" {
+		t.Fatalf("Expected a refreshed token within a minute of expiry, got %s", token.AccessToken)
+	}
+}
+
+func TestSourceForcedRefresh(t *testing.T) {
+	srv := newTokenServer(t, 3600)
+	src := ClientCredentials(Config{TokenURL: srv.URL})
+	ctx := context.Background()
+
+	stale, _ := src.Token(ctx)
+	fresh, err := src.Refresh(ctx, stale)
+	if err != nil || fresh.AccessToken != "token-2" {
+		t.Fatalf("Expected token-2, got %v, %v", fresh, err)
+	}
+	// A second caller that also saw the stale token gets the new one.
+	again, _ := src.Refresh(ctx, stale)
+	if again != fresh || srv.issued.Load() != 2 {
+		t.Fatalf("Expected no extra token request, got %s after %d requests", again.AccessToken, srv.issued.Load())
+	}
+}
+
+func TestSourceKeepsValidTokenWhenFetchFails(t *testing.T) {
+	var fail atomic.Bool
+	var fetches atomic.Int32
+	src := NewSource(func(context.Context) (*Token, error) {
+		fetches.Add(1)
+		if fail.Load() {
+			return nil, errors.New("endpoint down")
+		}
+		return &Token{AccessToken: "a", Expiry: time.Now().Add(30 * time.Second)}, nil
+	})
+
+	// The token lives under a minute, so it is renewed after 15 seconds.
+	first, _ := src.Token(context.Background())
+	src.now = func() time.Time { return time.Now().Add(20 * time.Second) }
+	fail.Store(true)
+	token, err := src.Token(context.Background())
+	if err != nil || token != first {
+		t.Fatalf("Expected the unexpired token, got %v, %v", token, err)
+	}
+	// The failed renewal is not tried again straight away.
+	src.Token(context.Background())
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("Expected 2 fetches, got %d", got)
+	}
+	src.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := src.Token(context.Background()); err == nil {
+		t.Fatal("Expected an error once the token has expired")
+	}
+}
+
+func TestSourceWaitersHonourContext(t *testing.T) {
+	release := make(chan struct{})
+	src := NewSource(func(context.Context) (*Token, error) {
+		<-release
+		return &Token{AccessToken: "a"}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := src.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the caller's deadline to end the wait, got %v", err)
+	}
+
+	// The fetch carries on for later callers.
+	done := make(chan *Token)
+	go func() {
+		token, _ := src.Token(context.Background())
+		done <- token
+	}()
+	close(release)
+	if token := <-done; token == nil || token.AccessToken != "a" {
+		t.Fatalf("Expected the fetched token, got %v", token)
+	}
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	srv := newTokenServer(t, 0)
+	src := RefreshToken(Config{TokenURL: srv.URL, ClientID: "app"}, "initial")
+	ctx := context.Background()
+
+	token, err := src.Token(ctx)
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	if form := srv.lastForm(); form["grant_type"] != "refresh_token" || form["refresh_token"] != "initial" {
+		t.Fatalf("Unexpected form %v", form)
+	}
+	if !token.Expiry.IsZero() {
+		t.Fatalf("Expected no expiry, got %v", token.Expiry)
+	}
+	if _, err := src.Refresh(ctx, token); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if form := srv.lastForm(); form["refresh_token"] != "refresh-1" {
+		t.Fatalf("Expected the rotated refresh token, got %v", form)
+	}
+}
+
+func TestTokenEndpointError(t *testing.T) {
+	srv := newTokenServer(t, 3600)
+	_, err := RefreshToken(Config{TokenURL: srv.URL}, "revoked").Token(context.Background())
+
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if oauthErr.StatusCode != http.StatusBadRequest || oauthErr.Code != "invalid_grant" || oauthErr.Description != "refresh token revoked" {
+		t.Fatalf("Unexpected error %+v", oauthErr)
+	}
+}
+
+func TestJWTBearer(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	for _, key := range []crypto.Signer{ecKey, rsaKey} {
+		srv := newTokenServer(t, 3600)
+		jwt := JWT{Issuer: "svc@example.com", Subject: "svc@example.com", Audience: srv.URL, KeyID: "k1", Key: key,
+			Claims: map[string]any{"scope": "orders"}}
+		if _, err := JWTBearer(Config{TokenURL: srv.URL}, jwt.Assertion).Token(context.Background()); err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+
+		form := srv.lastForm()
+		if form["grant_type"] != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Fatalf("Unexpected grant type %q", form["grant_type"])
+		}
+		header, claims := verifyJWT(t, form["assertion"], key.Public())
+		if header["kid"] != "k1" || claims["iss"] != "svc@example.com" || claims["aud"] != srv.URL || claims["scope"] != "orders" {
+			t.Fatalf("Unexpected assertion %v %v", header, claims)
+		}
+		if exp := claims["exp"].(float64) - claims["iat"].(float64); exp != 300 {
+			t.Fatalf("Expected a 5 minute lifetime, got %vs", exp)
+		}
+	}
+}
+
+func verifyJWT(t *testing.T, jwt string, pub crypto.PublicKey) (header, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Malformed JWT %q", jwt)
+	}
+	dec := base64.RawURLEncoding
+	h, _ := dec.DecodeString(parts[0])
+	c, _ := dec.DecodeString(parts[1])
+	sig, _ := dec.DecodeString(parts[2])
+	json.Unmarshal(h, &header)
+	json.Unmarshal(c, &claims)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if header["alg"] != "ES256" || !ecdsa.Verify(pub, digest[:], r, s) {
+			t.Fatalf("Invalid ES256 signature")
+		}
+	case *rsa.PublicKey:
+		if header["alg"] != "RS256" || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			t.Fatalf("Invalid RS256 signature")
+		}
+	}
+	return header, claims
+}
@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Algorithm adjusts a concurrency limit after each request. Update is
+// given the current limit, the request's round-trip time, how many
+// requests were in flight when it started and whether it was dropped,
+// meaning it failed in a way that suggests overload. It is called with the
+// limiter's lock held, so implementations need no locking of their own.
+type Algorithm interface {
+	Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// AIMD grows the limit by one after each successful request and shrinks
+// it by backoff, such as 0.9, after a drop. Requests slower than slow
+// count as drops; zero disables that check.
+func AIMD(backoff float64, slow time.Duration) Algorithm {
+	if backoff <= 0 || backoff >= 1 {
+		backoff = 0.9
+	}
+	return aimd{backoff: backoff, slow: slow}
+}
+
+type aimd struct {
+	backoff float64
+	slow    time.Duration
+}
+
+func (a aimd) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || (a.slow > 0 && rtt > a.slow) {
+		return limit * a.backoff
+	}
+	// Only grow a limit that is actually being used.
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// Vegas estimates how many requests are queued at the server by
+// comparing each round-trip time with the lowest one seen, and keeps that
+// queue between 3 and 6 times log10 of the limit. Drops shrink the limit
+// by 10%. The lowest round-trip time is re-learned every 1000 requests so
+// the limiter follows lasting changes in latency.
+func Vegas() Algorithm {
+	return &vegas{}
+}
+
+type vegas struct {
+	minRTT  time.Duration
+	samples int
+}
+
+func (v *vegas) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if v.samples++; v.samples%1000 == 0 || v.minRTT == 0 || rtt < v.minRTT {
+		v.minRTT = rtt
+	}
+	if dropped {
+		return limit * 0.9
+	}
+	if float64(inflight)*2 < limit || rtt <= 0 {
+		return limit
+	}
+
+	step := max(math.Log10(limit), 1)
+	queue := limit * (1 - float64(v.minRTT)/float64(rtt))
+	switch {
+	case queue < 3*step:
+		return limit + step
+	case queue > 6*step:
+		return limit - step
+	}
+	return limit
+}
+
+// AdaptiveConfig configures an Adaptive limiter. Zero values use the
+// documented defaults.
+type AdaptiveConfig struct {
+	// Initial is the starting limit. Defaults to 20.
+	Initial int
+
+	// Min and Max bound the limit. Default to 1 and 1000.
+	Min int
+	Max int
+
+	// Algorithm adjusts the limit. Defaults to AIMD(0.9, 0).
+	Algorithm Algorithm
+}
+
+// Adaptive caps the number of requests in flight at a limit that adapts
+// to the latency and errors of those requests. It is safe for concurrent
+// use.
+type Adaptive struct {
+	cfg AdaptiveConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	released chan struct{}
+}
+
+// NewAdaptive returns a limiter for cfg. An Initial, Min or Max of zero or
+// less defaults to 20, 1 or 1000, and a nil Algorithm to AIMD(0.9, 0).
+// Initial is then clamped between Min and Max.
+func NewAdaptive(cfg AdaptiveConfig) *Adaptive {
+	if cfg.Min <= 0 {
+		cfg.Min = 1
+	}
+	if cfg.Max <= 0 {
+		cfg.Max = 1000
+	}
+	if cfg.Initial <= 0 {
+		cfg.Initial = 20
+	}
+	if cfg.Algorithm == nil {
+		cfg.Algorithm = AIMD(0.9, 0)
+	}
+	return &Adaptive{
+		cfg:      cfg,
+		limit:    float64(min(max(cfg.Initial, cfg.Min), cfg.Max)),
+		released: make(chan struct{}),
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (a *Adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// InFlight returns the number of requests holding a permit.
+func (a *Adaptive) InFlight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight
+}
+
+// Acquire returns a permit for one request, or ErrLimitExceeded if the
+// limit is reached.
+func (a *Adaptive) Acquire() (*Permit, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.acquire()
+}
+
+// Wait returns a permit for one request, blocking while the limit is
+// reached. It returns ctx.Err() if ctx ends first.
+func (a *Adaptive) Wait(ctx context.Context) (*Permit, error) {
+	for {
+		a.mu.Lock()
+		p, err := a.acquire()
+		released := a.released
+		a.mu.Unlock()
+		if err == nil {
+			return p, nil
+		}
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// acquire takes a permit if the limit allows. a.mu must be held.
+func (a *Adaptive) acquire() (*Permit, error) {
+	if a.inflight >= int(a.limit) {
+		return nil, ErrLimitExceeded
+	}
+	a.inflight++
+	return &Permit{limiter: a, start: time.Now(), inflight: a.inflight}, nil
+}
+
+// release frees a permit, updating the limit with the request's outcome
+// unless sample is false, and wakes any waiters.
+func (a *Adaptive) release(p *Permit, sample, dropped bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inflight--
+	if sample {
+		limit := a.cfg.Algorithm.Update(a.limit, time.Since(p.start), p.inflight, dropped)
+		a.limit = min(max(limit, float64(a.cfg.Min)), float64(a.cfg.Max))
+	}
+	close(a.released)
+	a.released = make(chan struct{})
+}
+
+// Permit is a slot held by one request. Exactly one of Done or Release
+// must be called when the request finishes; later calls do nothing.
+type Permit struct {
+	limiter  *Adaptive
+	start    time.Time
+	inflight int
+	once     sync.Once
+}
+
+// Done frees the permit and feeds the request's round-trip time and
+// outcome to the limit. A non-nil err counts as a drop, except context
+// cancellation, which means the caller gave up rather than the server
+// being overloaded.
+func (p *Permit) Done(err error) {
+	p.once.Do(func() {
+		p.limiter.release(p, !errors.Is(err, context.Canceled), err != nil)
+	})
+}
+
+// Release frees the permit without affecting the limit, for requests that
+// never reached the server.
+func (p *Permit) Release() {
+	p.once.Do(func() {
+		p.limiter.release(p, false, false)
+	})
+}
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/messaging"
+)
+
+func TestAdaptiveRejectsAtLimit(t *testing.T) {
+	a := NewAdaptive(AdaptiveConfig{Initial: 2})
+
+	p1, err := a.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if _, err := a.Acquire(); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if _, err := a.Acquire(); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Expected ErrLimitExceeded, got %v", err)
+	}
+
+	p1.Release()
+	p1.Release()
+	if a.InFlight() != 1 {
+		t.Fatalf("Expected 1 in flight after release, got %d", a.InFlight())
+	}
+	if a.Limit() != 2 {
+		t.Fatalf("Expected Release to leave the limit alone, got %d", a.Limit())
+	}
+}
+
+func TestAdaptiveAIMD(t *testing.T) {
+	a := NewAdaptive(AdaptiveConfig{Initial: 10, Min: 2, Max: 12})
+
+	// A fully used limit grows by one per success, up to Max.
+	for i := 0; i < 5; i++ {
+		permits := make([]*Permit, 0, a.Limit())
+		for {
+			p, err := a.Acquire()
+			if err != nil {
+				break
+			}
+			permits = append(permits, p)
+		}
+		for _, p := range permits {
+			p.Done(nil)
+		}
+	}
+	if a.Limit() != 12 {
+		t.Fatalf("Expected the limit to grow to Max, got %d", a.Limit())
+	}
+
+	// Drops shrink it multiplicatively, down to Min.
+	for i := 0; i < 50; i++ {
+		p, _ := a.Acquire()
+		p.Done(errors.New("unavailable"))
+	}
+	if a.Limit() != 2 {
+		t.Fatalf("Expected the limit to shrink to Min, got %d", a.Limit())
+	}
+
+	// Cancellation is not the server's fault.
+	p, _ := a.Acquire()
+	p.Done(context.Canceled)
+	if a.Limit() != 2 {
+		t.Fatalf("Expected cancellation to leave the limit alone, got %d", a.Limit())
+	}
+}
+
+func TestAIMDTreatsSlowRequestsAsDrops(t *testing.T) {
+	alg := AIMD(0.5, 100*time.Millisecond)
+	if got := alg.Update(10, 200*time.Millisecond, 10, false); got != 5 {
+		t.Fatalf("Expected a slow request to halve the limit, got %v", got)
+	}
+	if got := alg.Update(10, 10*time.Millisecond, 10, false); got != 11 {
+		t.Fatalf("Expected a fast request to grow the limit, got %v", got)
+	}
+	if got := alg.Update(10, 10*time.Millisecond, 1, false); got != 10 {
+		t.Fatalf("Expected an unused limit not to grow, got %v", got)
+	}
+}
+
+func TestVegasFollowsQueueing(t *testing.T) {
+	alg := Vegas()
+	alg.Update(100, 10*time.Millisecond, 100, false)
+
+	if got := alg.Update(100, 10*time.Millisecond, 100, false); got <= 100 {
+		t.Fatalf("Expected no queueing to grow the limit, got %v", got)
+	}
+	if got := alg.Update(100, 50*time.Millisecond, 100, false); got >= 100 {
+		t.Fatalf("Expected latency far above the minimum to shrink the limit, got %v", got)
+	}
+	if got := alg.Update(100, 10*time.Millisecond, 100, true); got != 90 {
+		t.Fatalf("Expected a drop to shrink the limit by 10%%, got %v", got)
+	}
+}
+
+func TestAdaptiveWaitWakesOnRelease(t *testing.T) {
+	a := NewAdaptive(AdaptiveConfig{Initial: 1})
+	p, _ := a.Acquire()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		p.Release()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := a.Wait(ctx); err != nil {
+		t.Fatalf("Expected a permit after release, got %v", err)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := a.Wait(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWrapAdaptiveHandlerCapsConcurrency(t *testing.T) {
+	a := NewAdaptive(AdaptiveConfig{Initial: 2, Max: 2})
+	var mu sync.Mutex
+	var running, peak int
+	handler := WrapAdaptiveHandler(a, func(context.Context, messaging.Message) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+
+	done := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		go func() {
+			handler(context.Background(), messaging.Message{})
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	if peak > 2 {
+		t.Fatalf("Expected at most 2 messages at once, got %d", peak)
+	}
+}
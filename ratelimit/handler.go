@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/festus/microkit/messaging"
+)
+
+// WrapHandler throttles a messaging handler to l's rate for key. It always
+// waits for a token, whatever l's mode, so a consumer calling it slows
+// down instead of failing messages. It returns ctx.Err() if ctx ends while
+// waiting.
+func WrapHandler(l *Limiter, key string, handler messaging.HandlerFunc) messaging.HandlerFunc {
+	return func(ctx context.Context, msg messaging.Message) error {
+		if err := l.Wait(ctx, key); err != nil {
+			return err
+		}
+		return handler(ctx, msg)
+	}
+}
+
+// WrapAdaptiveHandler caps the messages handled at once by a, waiting for
+// a permit while the limit is reached. Handler errors count as drops, so
+// a failing or slowing downstream reduces the concurrency. It returns
+// ctx.Err() if ctx ends while waiting.
+func WrapAdaptiveHandler(a *Adaptive, handler messaging.HandlerFunc) messaging.HandlerFunc {
+	return func(ctx context.Context, msg messaging.Message) error {
+		p, err := a.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		err = handler(ctx, msg)
+		p.Done(err)
+		return err
+	}
+}
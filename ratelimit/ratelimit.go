@@ -0,0 +1,191 @@
+// Package ratelimit throttles outgoing requests and message handling.
+//
+// A Limiter is a set of token buckets, one per key such as a host or a
+// route, that either waits for a token or fails fast with ErrRateLimited.
+// An Adaptive limiter caps the requests in flight instead, growing and
+// shrinking the cap from observed latency and errors with AIMD or Vegas.
+//
+// Pass them to clients with network.WithRateLimit and
+// network.WithConcurrencyLimit, or wrap a messaging handler with
+// WrapHandler and WrapAdaptiveHandler to throttle consumption.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRateLimited is returned when no token is available in FailFast
+	// mode, or none will be before the context's deadline.
+	ErrRateLimited = errors.New("ratelimit: rate limit exceeded")
+
+	// ErrLimitExceeded is returned when an Adaptive limiter is at its
+	// concurrency limit.
+	ErrLimitExceeded = errors.New("ratelimit: concurrency limit exceeded")
+)
+
+// sweepInterval is how often a Limiter drops buckets that have refilled
+// completely, which behave like new ones.
+const sweepInterval = time.Minute
+
+// Mode is what a Limiter does when a bucket is empty.
+type Mode int
+
+const (
+	// Wait blocks until a token is available or the context ends.
+	Wait Mode = iota
+
+	// FailFast returns ErrRateLimited at once.
+	FailFast
+)
+
+// Limiter holds one token bucket per key, all refilling at the same rate.
+// Buckets are dropped once they are full again, so keys only cost memory
+// while they are in use; with a rate of zero they never refill and are
+// kept, so keys must then be bounded. It is safe for concurrent use.
+type Limiter struct {
+	rate  float64
+	burst float64
+	mode  Mode
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweepAt time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a Limiter allowing rate requests per second per key, with
+// bursts of up to burst requests. A rate of zero or less never refills a
+// bucket, so each key gets burst requests in all.
+func New(rate float64, burst int, mode Mode) *Limiter {
+	if !(rate > 0) {
+		rate = 0
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		mode:    mode,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take takes a token from key's bucket, waiting for one in Wait mode.
+func (l *Limiter) Take(ctx context.Context, key string) error {
+	if l.mode == FailFast {
+		if !l.Allow(key) {
+			return ErrRateLimited
+		}
+		return nil
+	}
+	return l.Wait(ctx, key)
+}
+
+// Allow takes a token from key's bucket if one is available.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(key)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Wait takes a token from key's bucket, blocking until it is available.
+// It fails with ErrRateLimited without waiting if the token would arrive
+// after ctx's deadline or never, and with ctx.Err() if ctx ends first.
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	l.mu.Lock()
+	b := l.refill(key)
+	b.tokens--
+	if b.tokens < 0 && l.rate == 0 {
+		// No token will ever arrive.
+		b.tokens++
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	wait := time.Duration(-b.tokens / l.rate * float64(time.Second))
+	if b.tokens >= 0 {
+		wait = 0
+	}
+	if deadline, ok := ctx.Deadline(); ok && wait > 0 && l.now().Add(wait).After(deadline) {
+		b.tokens++
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Hand the reserved token back.
+		l.mu.Lock()
+		b := l.refill(key)
+		b.tokens = min(b.tokens+1, l.burst)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill returns key's bucket topped up for the time since it was last
+// used. l.mu must be held.
+func (l *Limiter) refill(key string) *bucket {
+	now := l.now()
+	if l.rate > 0 && !now.Before(l.sweepAt) {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+		return b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed.Seconds()*l.rate, l.burst)
+		b.last = now
+	}
+	return b
+}
+
+// sweep drops the buckets that have refilled completely by now. l.mu must
+// be held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweepAt = now.Add(sweepInterval)
+}
+
+type routeKey struct{}
+
+// WithKey returns a context whose requests are rate limited under key
+// instead of their host (HTTP) or method (gRPC), so several routes can
+// share a bucket or one route can have its own.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routeKey{}, key)
+}
+
+// Key returns the key set with WithKey, or "".
+func Key(ctx context.Context) string {
+	key, _ := ctx.Value(routeKey{}).(string)
+	return key
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/messaging"
+)
+
+func fakeClock(l *Limiter) *time.Time {
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	return &now
+}
+
+func TestAllowRefillsAtRate(t *testing.T) {
+	l := New(2, 3, FailFast)
+	now := fakeClock(l)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("a") {
+			t.Fatalf("Expected burst token %d", i)
+		}
+	}
+	if l.Allow("a") {
+		t.Fatal("Expected the bucket to be empty")
+	}
+	if !l.Allow("b") {
+		t.Fatal("Expected keys to have their own buckets")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if !l.Allow("a") {
+		t.Fatal("Expected one token after half a second at 2/s")
+	}
+	if l.Allow("a") {
+		t.Fatal("Expected only one token to have been added")
+	}
+
+	*now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		l.Allow("a")
+	}
+	if l.Allow("a") {
+		t.Fatal("Expected refills to be capped at the burst")
+	}
+}
+
+func TestTakeFailFast(t *testing.T) {
+	l := New(1, 1, FailFast)
+	fakeClock(l)
+
+	if err := l.Take(context.Background(), "a"); err != nil {
+		t.Fatalf("Expected a token, got %v", err)
+	}
+	if err := l.Take(context.Background(), "a"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestWaitBlocksForToken(t *testing.T) {
+	l := New(20, 1, Wait)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Take(context.Background(), "a"); err != nil {
+			t.Fatalf("Take failed: %v", err)
+		}
+	}
+	// The burst token is free; the next two arrive 50ms apart.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("Expected to wait about 100ms, waited %v", elapsed)
+	}
+}
+
+func TestWaitFailsBeforeDeadline(t *testing.T) {
+	l := New(1, 1, Wait)
+	l.Allow("a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx, "a"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatal("Expected to fail without waiting for the deadline")
+	}
+
+	// The refused reservation is handed back.
+	l.mu.Lock()
+	tokens := l.buckets["a"].tokens
+	l.mu.Unlock()
+	if tokens < 0 {
+		t.Fatalf("Expected no token to stay reserved, got %v", tokens)
+	}
+}
+
+func TestNonPositiveRateNeverRefills(t *testing.T) {
+	for _, rate := range []float64{0, -1} {
+		l := New(rate, 1, Wait)
+		now := fakeClock(l)
+
+		if err := l.Wait(context.Background(), "a"); err != nil {
+			t.Fatalf("rate %v: expected the burst token, got %v", rate, err)
+		}
+		*now = now.Add(time.Hour)
+		if err := l.Wait(context.Background(), "a"); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("rate %v: expected ErrRateLimited, got %v", rate, err)
+		}
+		if l.Allow("a") {
+			t.Fatalf("rate %v: expected the bucket to stay empty", rate)
+		}
+	}
+}
+
+func TestWrapHandlerThrottles(t *testing.T) {
+	l := New(20, 1, FailFast)
+	var handled int
+	handler := WrapHandler(l, "orders", func(context.Context, messaging.Message) error {
+		handled++
+		return nil
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := handler(context.Background(), messaging.Message{}); err != nil {
+			t.Fatalf("Handler failed: %v", err)
+		}
+	}
+	if handled != 3 {
+		t.Fatalf("Expected 3 messages handled, got %d", handled)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("Expected consumption to be throttled, took %v", elapsed)
+	}
+}
+
+func TestFullBucketsAreDropped(t *testing.T) {
+	// One token every 100 seconds.
+	l := New(0.01, 1, FailFast)
+	now := fakeClock(l)
+
+	l.Allow("idle")
+	*now = now.Add(90 * time.Second)
+	l.Allow("busy")
+	*now = now.Add(70 * time.Second)
+	l.Allow("new")
+
+	if _, ok := l.buckets["idle"]; ok || len(l.buckets) != 2 {
+		t.Fatalf("Expected only the refilled bucket to be dropped, got %v", l.buckets)
+	}
+	if l.Allow("busy") {
+		t.Fatal("Expected the busy bucket to keep its state")
+	}
+	if !l.Allow("idle") {
+		t.Fatal("Expected a dropped bucket to start full")
+	}
+}
@@ -0,0 +1,108 @@
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the delay after a failed attempt.
+type Backoff interface {
+	NextDelay(attempt int) time.Duration
+}
+
+// Config is an exponential strategy with an attempt limit. It is what
+// network.WithRetry builds.
+type Config struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       bool
+}
+
+func (c Config) ShouldRetry(attempt int, err error) bool {
+	return attempt < c.MaxAttempts
+}
+
+func (c Config) NextDelay(attempt int) time.Duration {
+	return Exponential{
+		Initial:    c.InitialDelay,
+		Max:        c.MaxDelay,
+		Multiplier: c.Multiplier,
+		Jitter:     c.Jitter,
+	}.NextDelay(attempt)
+}
+
+// Exponential multiplies the delay by Multiplier after every attempt, up
+// to Max. With Jitter each delay is randomised to 50-100% of its value to
+// avoid thundering herds.
+type Exponential struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     bool
+}
+
+func (e Exponential) NextDelay(attempt int) time.Duration {
+	mult := e.Multiplier
+	if mult <= 0 {
+		mult = 1
+	}
+
+	delay := capDelay(float64(e.Initial)*math.Pow(mult, float64(attempt-1)), e.Max)
+	if e.Jitter {
+		delay = time.Duration(float64(delay) * (0.5 + rand.Float64()*0.5))
+	}
+	return delay
+}
+
+// Constant waits the same Delay after every attempt.
+type Constant struct {
+	Delay time.Duration
+}
+
+func (c Constant) NextDelay(attempt int) time.Duration {
+	return c.Delay
+}
+
+// Fibonacci grows the delay along the Fibonacci sequence (1, 1, 2, 3, 5...
+// times Initial), up to Max.
+type Fibonacci struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+func (f Fibonacci) NextDelay(attempt int) time.Duration {
+	a, b := 1.0, 1.0
+	for i := 1; i < attempt; i++ {
+		a, b = b, a+b
+	}
+	return capDelay(float64(f.Initial)*a, f.Max)
+}
+
+// DecorrelatedJitter picks each delay uniformly between Base and three
+// times the previous upper bound, capped at Max. The bound is derived from
+// the attempt number, so one value can be shared by concurrent callers.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (d DecorrelatedJitter) NextDelay(attempt int) time.Duration {
+	upper := capDelay(float64(d.Base)*math.Pow(3, float64(attempt)), d.Max)
+	if upper <= d.Base {
+		return upper
+	}
+	return d.Base + time.Duration(rand.Int63n(int64(upper-d.Base)))
+}
+
+func capDelay(delay float64, max time.Duration) time.Duration {
+	if max > 0 && delay > float64(max) {
+		return max
+	}
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,210 @@
+// Package retry runs operations with pluggable retry strategies.
+//
+// A Strategy decides whether a failed attempt is retried and how long to
+// wait first. Policy combines one of the backoffs in this package with an
+// attempt limit and an error classifier; Config is the exponential
+// strategy used by network.WithRetry. Errors wrapped with Permanent are
+// never retried, and errors wrapped with RetryAfter override the delay.
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrRetryableStatus = errors.New("retryable status code")
+
+// Strategy decides whether and when to retry. Attempts are numbered from 1.
+// Implementations must be safe for concurrent use.
+type Strategy interface {
+	// ShouldRetry reports whether another attempt should follow attempt,
+	// which failed with err.
+	ShouldRetry(attempt int, err error) bool
+
+	// NextDelay returns how long to wait after attempt before the next one.
+	NextDelay(attempt int) time.Duration
+}
+
+// Option configures a single Do call.
+type Option func(*settings)
+
+type settings struct {
+	maxElapsed     time.Duration
+	maxDelay       time.Duration
+	attemptTimeout time.Duration
+	onRetry        []func(attempt int, err error, delay time.Duration)
+}
+
+// WithMaxElapsed stops retrying once the next attempt would start more
+// than d after the first one.
+func WithMaxElapsed(d time.Duration) Option {
+	return func(s *settings) {
+		s.maxElapsed = d
+	}
+}
+
+// WithMaxDelay caps every delay between attempts, including delays asked
+// for with RetryAfter.
+func WithMaxDelay(d time.Duration) Option {
+	return func(s *settings) {
+		s.maxDelay = d
+	}
+}
+
+// WithAttemptTimeout bounds each attempt with its own deadline.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		s.attemptTimeout = d
+	}
+}
+
+// OnRetry registers a hook called before each retry with the failed
+// attempt, its error and the delay about to be waited.
+func OnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(s *settings) {
+		s.onRetry = append(s.onRetry, fn)
+	}
+}
+
+// Do calls fn until it succeeds, the strategy gives up, the error is
+// permanent, or ctx ends. It returns the last error from fn, or ctx.Err()
+// if ctx ended while waiting between attempts.
+func Do(ctx context.Context, s Strategy, fn func(ctx context.Context) error, opts ...Option) error {
+	var cfg settings
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := runAttempt(ctx, cfg.attemptTimeout, fn)
+		if err == nil {
+			return nil
+		}
+
+		var perm *PermanentError
+		if errors.As(err, &perm) {
+			return perm.Err
+		}
+		if ctx.Err() != nil || !s.ShouldRetry(attempt, err) {
+			return err
+		}
+
+		delay := s.NextDelay(attempt)
+		if d, ok := RetryAfterDelay(err); ok {
+			delay = d
+		}
+		if cfg.maxDelay > 0 && delay > cfg.maxDelay {
+			delay = cfg.maxDelay
+		}
+		if cfg.maxElapsed > 0 && time.Since(start)+delay > cfg.maxElapsed {
+			return err
+		}
+
+		for _, hook := range cfg.onRetry {
+			hook(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func runAttempt(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// Execute retries fn with the exponential strategy described by config.
+func Execute(ctx context.Context, config Config, fn func() error) error {
+	return Do(ctx, config, func(context.Context) error {
+		return fn()
+	})
+}
+
+// Policy is a Strategy built from a Backoff, an attempt limit and an
+// optional error classifier.
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Zero means no limit; bound the retries with ctx or WithMaxElapsed.
+	MaxAttempts int
+
+	// Backoff computes the delay between attempts. Nil means no delay.
+	Backoff Backoff
+
+	// Retryable reports whether err is worth retrying. Nil retries every
+	// error that is not Permanent.
+	Retryable func(err error) bool
+}
+
+func (p Policy) ShouldRetry(attempt int, err error) bool {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+func (p Policy) NextDelay(attempt int) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff.NextDelay(attempt)
+}
+
+// PermanentError marks an error that must not be retried.
+type PermanentError struct {
+	Err error
+}
+
+// Permanent wraps err so Do returns it without retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// RetryAfterError asks for the next attempt to wait Delay, typically
+// because the server said so.
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+// RetryAfter wraps err so Do waits d before the next attempt instead of
+// the strategy's delay.
+func RetryAfter(err error, d time.Duration) error {
+	return &RetryAfterError{Err: err, Delay: d}
+}
+
+func (e *RetryAfterError) Error() string {
+	if e.Err == nil {
+		return "retry after " + e.Delay.String()
+	}
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfterDelay returns the delay requested by a RetryAfterError in
+// err's chain.
+func RetryAfterDelay(err error) (time.Duration, bool) {
+	var ra *RetryAfterError
+	if errors.As(err, &ra) {
+		return ra.Delay, true
+	}
+	return 0, false
+}
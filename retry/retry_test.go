@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	var hooked []int
+	err := Do(context.Background(), Policy{MaxAttempts: 5, Backoff: Constant{Delay: time.Millisecond}},
+		func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		},
+		OnRetry(func(attempt int, err error, delay time.Duration) {
+			hooked = append(hooked, attempt)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if calls != 3 || len(hooked) != 2 || hooked[1] != 2 {
+		t.Fatalf("Expected 3 calls and hooks for attempts 1 and 2, got %d calls, hooks %v", calls, hooked)
+	}
+}
+
+func TestPermanentStopsImmediately(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Policy{MaxAttempts: 5}, func(ctx context.Context) error {
+		calls++
+		return Permanent(errTransient)
+	})
+	if calls != 1 || err != errTransient {
+		t.Fatalf("Expected one call returning the unwrapped error, got %d calls, %v", calls, err)
+	}
+}
+
+func TestRetryableClassifier(t *testing.T) {
+	calls := 0
+	p := Policy{MaxAttempts: 5, Retryable: func(err error) bool { return errors.Is(err, errTransient) }}
+	Do(context.Background(), p, func(ctx context.Context) error {
+		calls++
+		return errors.New("invalid argument")
+	})
+	if calls != 1 {
+		t.Fatalf("Expected non-retryable error to stop after 1 call, got %d", calls)
+	}
+}
+
+func TestRetryAfterOverridesDelay(t *testing.T) {
+	var delays []time.Duration
+	Do(context.Background(), Policy{MaxAttempts: 2, Backoff: Constant{Delay: time.Hour}},
+		func(ctx context.Context) error {
+			return RetryAfter(errTransient, time.Millisecond)
+		},
+		OnRetry(func(attempt int, err error, delay time.Duration) {
+			delays = append(delays, delay)
+		}),
+	)
+	if len(delays) != 1 || delays[0] != time.Millisecond {
+		t.Fatalf("Expected Retry-After delay of 1ms, got %v", delays)
+	}
+
+	delays = nil
+	Do(context.Background(), Policy{MaxAttempts: 2},
+		func(ctx context.Context) error {
+			return RetryAfter(errTransient, time.Hour)
+		},
+		WithMaxDelay(time.Millisecond),
+		OnRetry(func(attempt int, err error, delay time.Duration) {
+			delays = append(delays, delay)
+		}),
+	)
+	if len(delays) != 1 || delays[0] != time.Millisecond {
+		t.Fatalf("Expected Retry-After to be capped at 1ms, got %v", delays)
+	}
+}
+
+func TestMaxElapsedAndAttemptTimeout(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := Do(context.Background(), Policy{Backoff: Constant{Delay: 10 * time.Millisecond}},
+		func(ctx context.Context) error {
+			calls++
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		WithAttemptTimeout(5*time.Millisecond),
+		WithMaxElapsed(50*time.Millisecond),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected attempt deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond || calls < 2 {
+		t.Fatalf("Expected several short attempts within max elapsed, got %d calls in %v", calls, elapsed)
+	}
+}
+
+func TestBackoffs(t *testing.T) {
+	fib := Fibonacci{Initial: time.Second, Max: 6 * time.Second}
+	for i, want := range []time.Duration{1, 1, 2, 3, 5, 6} {
+		if got := fib.NextDelay(i + 1); got != want*time.Second {
+			t.Fatalf("Fibonacci attempt %d: expected %v, got %v", i+1, want*time.Second, got)
+		}
+	}
+
+	exp := Exponential{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	if got := exp.NextDelay(3); got != 400*time.Millisecond {
+		t.Fatalf("Exponential attempt 3: expected 400ms, got %v", got)
+	}
+	if got := exp.NextDelay(10); got != time.Second {
+		t.Fatalf("Exponential should cap at Max, got %v", got)
+	}
+
+	dj := DecorrelatedJitter{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+	for attempt := 1; attempt < 10; attempt++ {
+		if got := dj.NextDelay(attempt); got < dj.Base || got > dj.Max {
+			t.Fatalf("DecorrelatedJitter attempt %d out of range: %v", attempt, got)
+		}
+	}
+}
+
+func TestExecuteCompat(t *testing.T) {
+	calls := 0
+	err := Execute(context.Background(), Config{MaxAttempts: 3, InitialDelay: time.Millisecond, Multiplier: 2}, func() error {
+		calls++
+		return errTransient
+	})
+	if calls != 3 || err != errTransient {
+		t.Fatalf("Expected 3 calls and last error, got %d, %v", calls, err)
+	}
+}
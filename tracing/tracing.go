@@ -0,0 +1,193 @@
+// Package tracing propagates OpenTelemetry trace context across microkit
+// producers, consumers and network clients.
+//
+// Adapters use the global tracer provider unless given a Tracer built with
+// WithTracerProvider, so tracing stays a no-op until the application
+// configures OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/festus/microkit/messaging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/festus/microkit"
+
+// Tracer starts spans and propagates trace context for microkit adapters.
+// The zero value is not usable; use New.
+type Tracer struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// Option configures a Tracer.
+type Option func(*Tracer)
+
+// WithTracerProvider sets the provider spans are created from.
+// Defaults to the global provider at the time each span is started.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.provider = tp
+	}
+}
+
+// WithPropagator sets the propagator used to inject and extract context.
+// Defaults to W3C trace context plus baggage.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = p
+	}
+}
+
+// New returns a Tracer configured with opts.
+func New(opts ...Option) *Tracer {
+	t := &Tracer{
+		propagator: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Tracer) tracer() trace.Tracer {
+	tp := t.provider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+// Inject writes the trace context and baggage from ctx into carrier.
+func (t *Tracer) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	t.propagator.Inject(ctx, carrier)
+}
+
+// Extract returns ctx updated with the trace context and baggage in carrier.
+func (t *Tracer) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return t.propagator.Extract(ctx, carrier)
+}
+
+// StartPublish starts a producer span for publishing msg to topic and
+// injects the span context into a copy of msg.Headers.
+func (t *Tracer) StartPublish(ctx context.Context, system, topic string, msg *messaging.Message) (context.Context, trace.Span) {
+	ctx, span := t.tracer().Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messageAttributes(system, topic, "publish", *msg)...),
+	)
+
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	t.Inject(ctx, propagation.MapCarrier(headers))
+	msg.Headers = headers
+	return ctx, span
+}
+
+// StartProcess extracts the producer's context from msg.Headers and starts
+// a consumer span for handling msg. The span is a child of, and linked to,
+// the producer span; baggage is carried into the returned context.
+func (t *Tracer) StartProcess(ctx context.Context, system, topic string, msg messaging.Message) (context.Context, trace.Span) {
+	ctx = t.Extract(ctx, propagation.MapCarrier(msg.Headers))
+
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messageAttributes(system, topic, "process", msg)...),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+	return t.tracer().Start(ctx, "process "+topic, opts...)
+}
+
+// StartHTTP starts a client span for req and injects its context into the
+// request headers. The returned request carries the span's context.
+func (t *Tracer) StartHTTP(req *http.Request) (*http.Request, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.full", req.URL.Redacted()),
+		attribute.String("server.address", req.URL.Hostname()),
+	}
+	if port := req.URL.Port(); port != "" {
+		if p, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, attribute.Int("server.port", p))
+		}
+	}
+
+	ctx, span := t.tracer().Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	req = req.WithContext(ctx)
+	t.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, span
+}
+
+// EndHTTP records the response status and err on span and ends it.
+// Status codes of 500 and above mark the span as failed.
+func EndHTTP(span trace.Span, statusCode int, err error) {
+	if statusCode > 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+		if statusCode >= 500 && err == nil {
+			span.SetStatus(codes.Error, http.StatusText(statusCode))
+		}
+	}
+	End(span, err)
+}
+
+// StartRPC starts a client span for a gRPC call to fullMethod
+// ("/package.Service/Method").
+func (t *Tracer) StartRPC(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	service, method := splitMethod(fullMethod)
+	return t.tracer().Start(ctx, service+"/"+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		),
+	)
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func messageAttributes(system, topic, operation string, msg messaging.Message) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.system", system),
+		attribute.String("messaging.destination.name", topic),
+		attribute.String("messaging.operation.type", operation),
+		attribute.Int("messaging.message.body.size", len(msg.Payload)),
+	}
+	if msg.ID != "" {
+		attrs = append(attrs, attribute.String("messaging.message.id", msg.ID))
+	}
+	return attrs
+}
+
+func splitMethod(fullMethod string) (service, method string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
@@ -0,0 +1,159 @@
+package tracing_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/adapters/grpc"
+	microhttp "github.com/festus/microkit/adapters/http"
+	"github.com/festus/microkit/adapters/memory"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/tracing"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func newTracer() (*tracing.Tracer, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	return tracing.New(tracing.WithTracerProvider(tp)), exp
+}
+
+func findSpan(t *testing.T, exp *tracetest.InMemoryExporter, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range exp.GetSpans() {
+		if s.SpanKind == kind {
+			return s
+		}
+	}
+	t.Fatalf("No %s span recorded", kind)
+	return tracetest.SpanStub{}
+}
+
+func TestMessagingPropagation(t *testing.T) {
+	tracer, exp := newTracer()
+	broker := memory.NewBroker(messaging.DefaultConfig(), memory.WithTracer(tracer))
+	defer broker.Close()
+
+	ctx := context.Background()
+	member, _ := baggage.NewMember("tenant", "acme")
+	bag, _ := baggage.New(member)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	done := make(chan context.Context, 1)
+	broker.Subscribe(ctx, "orders", func(ctx context.Context, msg messaging.Message) error {
+		if msg.Headers["traceparent"] == "" {
+			t.Error("Expected traceparent header on consumed message")
+		}
+		done <- ctx
+		return nil
+	})
+
+	if err := broker.Publish(ctx, "orders", messaging.Message{Payload: []byte("x")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	var handlerCtx context.Context
+	select {
+	case handlerCtx = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+	// Give the consumer span time to end after the handler returns.
+	time.Sleep(50 * time.Millisecond)
+
+	if got := baggage.FromContext(handlerCtx).Member("tenant").Value(); got != "acme" {
+		t.Fatalf("Expected baggage tenant=acme, got %q", got)
+	}
+
+	producer := findSpan(t, exp, trace.SpanKindProducer)
+	consumer := findSpan(t, exp, trace.SpanKindConsumer)
+
+	if consumer.Parent.SpanID() != producer.SpanContext.SpanID() {
+		t.Fatal("Consumer span should be a child of the producer span")
+	}
+	if len(consumer.Links) != 1 || consumer.Links[0].SpanContext.SpanID() != producer.SpanContext.SpanID() {
+		t.Fatal("Consumer span should link to the producer span")
+	}
+	if consumer.Name != "process orders" {
+		t.Fatalf("Unexpected consumer span name %q", consumer.Name)
+	}
+}
+
+func TestHTTPPropagation(t *testing.T) {
+	tracer, exp := newTracer()
+
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	client := microhttp.NewClient(time.Second, microhttp.WithTracer(tracer))
+	defer client.Close()
+
+	if _, err := client.Get(context.Background(), srv.URL); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	span := findSpan(t, exp, trace.SpanKindClient)
+	got := (<-headers).Get("traceparent")
+	if got == "" || got[36:52] != span.SpanContext.SpanID().String() {
+		t.Fatalf("Expected traceparent carrying span %s, got %q", span.SpanContext.SpanID(), got)
+	}
+	for _, attr := range span.Attributes {
+		if attr.Key == "http.response.status_code" && attr.Value.AsInt64() == http.StatusTeapot {
+			return
+		}
+	}
+	t.Fatal("Expected http.response.status_code attribute")
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	tracer, exp := newTracer()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	incoming := make(chan metadata.MD, 1)
+	srv := grpclib.NewServer(grpclib.UnaryInterceptor(func(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		incoming <- md
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	client, err := grpc.NewClient(lis.Addr().String(), time.Second, grpc.WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Call(context.Background(), "/grpc.health.v1.Health/Check",
+		&healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	span := findSpan(t, exp, trace.SpanKindClient)
+	if span.Name != "grpc.health.v1.Health/Check" {
+		t.Fatalf("Unexpected span name %q", span.Name)
+	}
+	if tp := (<-incoming).Get("traceparent"); len(tp) == 0 {
+		t.Fatal("Expected traceparent in outgoing metadata")
+	}
+}
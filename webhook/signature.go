@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every webhook. They follow the Standard Webhooks
+// specification, so receivers can verify them with its libraries as well
+// as with Verify.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+
+	// HeaderEvent carries the event type given to Send.
+	HeaderEvent = "Webhook-Event"
+)
+
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrTimestampSkew    = errors.New("webhook: timestamp outside tolerance")
+)
+
+// Sign returns the signature header value for a webhook: "v1," followed
+// by the base64 HMAC-SHA256 of "<id>.<unix timestamp>.<payload>".
+func Sign(secret []byte, id string, ts time.Time, payload []byte) string {
+	return "v1," + base64.StdEncoding.EncodeToString(signature(secret, id, ts.Unix(), payload))
+}
+
+// Verify checks the headers of a received webhook against payload. The
+// signature header may list several space-separated signatures, as sent
+// while a secret is rotated; one matching is enough. Timestamps further
+// than tolerance from now are rejected, which bounds replays.
+func Verify(secret []byte, h http.Header, payload []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrTimestampSkew
+	}
+
+	want := signature(secret, h.Get(HeaderID), ts, payload)
+	for _, sig := range strings.Fields(h.Get(HeaderSignature)) {
+		version, value, _ := strings.Cut(sig, ",")
+		if version != "v1" {
+			continue
+		}
+		got, err := base64.StdEncoding.DecodeString(value)
+		if err == nil && hmac.Equal(got, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func signature(secret []byte, id string, ts int64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + strconv.FormatInt(ts, 10) + "."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
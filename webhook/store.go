@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Store keeps endpoints, deliveries and the attempts made for them. It
+// is the source of truth for the retry schedule, so it must be shared by
+// every Dispatcher instance and outlive restarts; MemoryStore is for
+// tests and local development. Methods return ErrNotFound for unknown
+// IDs.
+type Store interface {
+	SaveEndpoint(ctx context.Context, ep Endpoint) error
+	Endpoint(ctx context.Context, id string) (Endpoint, error)
+
+	SaveDelivery(ctx context.Context, d Delivery) error
+	Delivery(ctx context.Context, id string) (Delivery, error)
+
+	// Claim moves a delivery whose Status equals from and whose Attempts
+	// equals attempts to StatusSending, with NextAttempt set to until, and
+	// returns it. It reports false, changing nothing, if the delivery is
+	// in any other state. It must be atomic, so that only one of several
+	// concurrent callers succeeds.
+	Claim(ctx context.Context, id string, from Status, attempts int, until time.Time) (Delivery, bool, error)
+
+	// Due returns up to limit pending, queued or sending deliveries whose
+	// NextAttempt is not after now, earliest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+
+	AddAttempt(ctx context.Context, a Attempt) error
+	// Attempts returns the attempts made for a delivery, oldest first.
+	Attempts(ctx context.Context, deliveryID string) ([]Attempt, error)
+}
+
+// MemoryStore is a Store in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu         sync.Mutex
+	endpoints  map[string]Endpoint
+	deliveries map[string]Delivery
+	attempts   map[string][]Attempt
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		endpoints:  make(map[string]Endpoint),
+		deliveries: make(map[string]Delivery),
+		attempts:   make(map[string][]Attempt),
+	}
+}
+
+func (s *MemoryStore) SaveEndpoint(_ context.Context, ep Endpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep.Secret = slices.Clone(ep.Secret)
+	s.endpoints[ep.ID] = ep
+	return nil
+}
+
+func (s *MemoryStore) Endpoint(_ context.Context, id string) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep, ok := s.endpoints[id]
+	if !ok {
+		return Endpoint{}, ErrNotFound
+	}
+	return ep, nil
+}
+
+func (s *MemoryStore) SaveDelivery(_ context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+func (s *MemoryStore) Delivery(_ context.Context, id string) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	return d, nil
+}
+
+func (s *MemoryStore) Claim(_ context.Context, id string, from Status, attempts int, until time.Time) (Delivery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return Delivery{}, false, ErrNotFound
+	}
+	if d.Status != from || d.Attempts != attempts {
+		return Delivery{}, false, nil
+	}
+	d.Status = StatusSending
+	d.NextAttempt = until
+	s.deliveries[id] = d
+	return d, true, nil
+}
+
+func (s *MemoryStore) Due(_ context.Context, now time.Time, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Delivery
+	for _, d := range s.deliveries {
+		if (d.Status == StatusPending || d.Status == StatusQueued || d.Status == StatusSending) && !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	slices.SortFunc(due, func(a, b Delivery) int {
+		return a.NextAttempt.Compare(b.NextAttempt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *MemoryStore) AddAttempt(_ context.Context, a Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[a.DeliveryID] = append(s.attempts[a.DeliveryID], a)
+	return nil
+}
+
+func (s *MemoryStore) Attempts(_ context.Context, deliveryID string) ([]Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.attempts[deliveryID]), nil
+}
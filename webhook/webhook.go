@@ -0,0 +1,515 @@
+// Package webhook delivers outbound webhooks to customer endpoints.
+//
+// Send stores a Delivery and queues it on a messaging topic; the
+// Dispatcher's subscription POSTs the payload, signed with the
+// endpoint's secret, and records every Attempt in a Store. Failed
+// deliveries are retried with exponential backoff, by default 12 attempts
+// over four to eight hours, by a scheduler that requeues due deliveries
+// from the Store, so retries survive restarts and never block a consumer.
+// Endpoints that keep failing for a day (see WithDisableAfter) are
+// disabled until EnableEndpoint is called, and Redeliver resends any
+// delivery by hand.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	microhttp "github.com/festus/microkit/adapters/http"
+	"github.com/festus/microkit/internal/logging"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/network"
+	"github.com/festus/microkit/retry"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook: not found")
+	ErrEndpointDisabled = errors.New("webhook: endpoint disabled")
+	ErrDeliveryBusy     = errors.New("webhook: delivery is being sent")
+)
+
+// Endpoint is a customer URL that receives webhooks.
+type Endpoint struct {
+	ID     string
+	URL    string
+	Secret []byte
+
+	Disabled   bool
+	DisabledAt time.Time
+
+	// FailingSince is when the current run of failed attempts began, or
+	// zero if the last attempt succeeded.
+	FailingSince time.Time
+}
+
+// Status is where a delivery stands.
+type Status string
+
+const (
+	// StatusPending waits for NextAttempt to be queued again.
+	StatusPending Status = "pending"
+	// StatusQueued is on the topic, waiting for the consumer.
+	StatusQueued Status = "queued"
+	// StatusSending has been claimed by a consumer making an attempt.
+	StatusSending   Status = "sending"
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed gave up after the last attempt, or because the
+	// endpoint was disabled.
+	StatusFailed Status = "failed"
+)
+
+// Delivery is one event sent to one endpoint.
+type Delivery struct {
+	ID         string
+	EndpointID string
+	EventType  string
+	Payload    []byte
+	CreatedAt  time.Time
+
+	Status   Status
+	Attempts int
+
+	// NextAttempt is when a pending delivery is queued again. For a queued
+	// or sending one it is when the delivery is presumed lost and queued
+	// again.
+	NextAttempt time.Time
+}
+
+// Attempt records one HTTP request made for a delivery.
+type Attempt struct {
+	DeliveryID string
+	Number     int
+	At         time.Time
+	Duration   time.Duration
+
+	// StatusCode is zero when no response arrived.
+	StatusCode int
+	// Response holds up to the first KiB of the response body.
+	Response []byte
+	// Error describes the failure, and is empty for a 2xx response.
+	Error string
+	// Manual is set for attempts made by Redeliver.
+	Manual bool
+}
+
+// Succeeded reports whether the endpoint accepted the webhook.
+func (a Attempt) Succeeded() bool {
+	return a.Error == ""
+}
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithTopic sets the messaging topic deliveries are queued on. Defaults
+// to "webhooks".
+func WithTopic(topic string) Option {
+	return func(d *Dispatcher) {
+		d.topic = topic
+	}
+}
+
+// WithHTTPClient sets the client that sends webhooks. Defaults to a
+// client with a 30 second timeout. Do not configure network retries on
+// it; the Dispatcher schedules its own.
+func WithHTTPClient(c *microhttp.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = c
+	}
+}
+
+// WithBackoff sets the delay before each retry. Defaults to exponential
+// backoff from 30 seconds, doubling up to 2 hours, with jitter.
+func WithBackoff(b retry.Backoff) Option {
+	return func(d *Dispatcher) {
+		d.backoff = b
+	}
+}
+
+// WithMaxAttempts sets how many attempts a delivery gets before it fails.
+// Defaults to 12.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = n
+	}
+}
+
+// WithDisableAfter sets how long an endpoint may fail every attempt
+// before it is disabled. Defaults to 24 hours.
+func WithDisableAfter(dur time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.disableAfter = dur
+	}
+}
+
+// WithPollInterval sets how often the Store is checked for deliveries due
+// for another attempt. Defaults to 10 seconds.
+func WithPollInterval(dur time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.pollInterval = dur
+	}
+}
+
+// WithLogger sets the logger for failed deliveries and disabled
+// endpoints. Defaults to a logger that discards everything.
+func WithLogger(l *slog.Logger) Option {
+	return func(d *Dispatcher) {
+		d.logger = l
+	}
+}
+
+// queueTimeout is how long a queued or sending delivery may go unhandled
+// before the scheduler presumes it lost and queues it again.
+const queueTimeout = 10 * time.Minute
+
+// Dispatcher sends webhooks. It is safe for concurrent use.
+type Dispatcher struct {
+	producer     messaging.Producer
+	consumer     messaging.Consumer
+	store        Store
+	client       *microhttp.Client
+	topic        string
+	backoff      retry.Backoff
+	maxAttempts  int
+	disableAfter time.Duration
+	pollInterval time.Duration
+	logger       *slog.Logger
+	now          func() time.Time
+
+	// endpointMu serialises endpoint health updates.
+	endpointMu sync.Mutex
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func New(producer messaging.Producer, consumer messaging.Consumer, store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		producer:     producer,
+		consumer:     consumer,
+		store:        store,
+		topic:        "webhooks",
+		backoff:      retry.Exponential{Initial: 30 * time.Second, Max: 2 * time.Hour, Multiplier: 2, Jitter: true},
+		maxAttempts:  12,
+		disableAfter: 24 * time.Hour,
+		pollInterval: 10 * time.Second,
+		logger:       logging.Discard(),
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.client == nil {
+		d.client = microhttp.NewClient(30 * time.Second)
+	}
+	return d
+}
+
+// Send queues payload, a JSON document, for delivery to the endpoint and
+// returns the delivery ID, which is also sent as the Webhook-Id header so
+// receivers can drop duplicates. It returns ErrEndpointDisabled for
+// disabled endpoints.
+func (d *Dispatcher) Send(ctx context.Context, endpointID, eventType string, payload []byte) (string, error) {
+	ep, err := d.store.Endpoint(ctx, endpointID)
+	if err != nil {
+		return "", err
+	}
+	if ep.Disabled {
+		return "", ErrEndpointDisabled
+	}
+
+	del := Delivery{
+		ID:         newID(),
+		EndpointID: endpointID,
+		EventType:  eventType,
+		Payload:    payload,
+		CreatedAt:  d.now(),
+	}
+	if err := d.enqueue(ctx, del); err != nil {
+		return "", err
+	}
+	return del.ID, nil
+}
+
+// enqueue marks del queued and publishes it. If publishing fails the
+// delivery is left pending, for the scheduler to queue later.
+func (d *Dispatcher) enqueue(ctx context.Context, del Delivery) error {
+	// The delivery is marked queued first, so the consumer never sees a
+	// message for a delivery that is not.
+	del.Status = StatusQueued
+	del.NextAttempt = d.now().Add(queueTimeout)
+	if err := d.store.SaveDelivery(ctx, del); err != nil {
+		return fmt.Errorf("webhook: save delivery: %w", err)
+	}
+
+	if err := d.producer.Publish(ctx, d.topic, deliveryMessage(del)); err != nil {
+		d.logger.Warn("webhook: queue delivery failed", "delivery", del.ID, "error", err)
+		del.Status = StatusPending
+		del.NextAttempt = d.now()
+		if err := d.store.SaveDelivery(ctx, del); err != nil {
+			return fmt.Errorf("webhook: save delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start subscribes to the topic and starts the scheduler that queues due
+// retries. Both stop when ctx ends; Shutdown also stops the scheduler.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return errors.New("webhook: dispatcher already started")
+	}
+
+	if err := d.consumer.Subscribe(ctx, d.topic, d.handle); err != nil {
+		return err
+	}
+	ctx, d.stop = context.WithCancel(ctx)
+	d.done = make(chan struct{})
+	go d.schedule(ctx)
+	return nil
+}
+
+// Shutdown stops the scheduler and waits for it to return or ctx to end.
+// Drain the consumer separately to finish in-flight deliveries.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) schedule(ctx context.Context) {
+	defer close(d.done)
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := d.store.Due(ctx, d.now(), 100)
+		if err != nil {
+			d.logger.Warn("webhook: load due deliveries failed", "error", err)
+			continue
+		}
+		for _, del := range due {
+			if err := d.enqueue(ctx, del); err != nil {
+				d.logger.Warn("webhook: requeue failed", "delivery", del.ID, "error", err)
+			}
+		}
+	}
+}
+
+// deliveryMessage names del and the attempt it was queued for, so that
+// copies of the message left over from an earlier attempt are skipped.
+func deliveryMessage(del Delivery) messaging.Message {
+	return messaging.Message{
+		Payload: []byte(del.ID + ":" + strconv.Itoa(del.Attempts)),
+		Headers: map[string]string{"webhook-endpoint": del.EndpointID},
+	}
+}
+
+// handle makes the next attempt of the delivery named by msg. It claims
+// the delivery first, so that of several copies of a message, whether
+// redelivered by the broker or queued again by the scheduler, only one
+// makes the attempt; the others are skipped.
+func (d *Dispatcher) handle(ctx context.Context, msg messaging.Message) error {
+	id, gen, _ := strings.Cut(string(msg.Payload), ":")
+	attempts, err := strconv.Atoi(gen)
+	if err != nil {
+		d.logger.Warn("webhook: malformed message", "payload", string(msg.Payload))
+		return nil
+	}
+	del, ok, err := d.store.Claim(ctx, id, StatusQueued, attempts, d.now().Add(queueTimeout))
+	if errors.Is(err, ErrNotFound) {
+		d.logger.Warn("webhook: unknown delivery", "delivery", id)
+		return nil
+	}
+	if err != nil || !ok {
+		return err
+	}
+
+	ep, err := d.store.Endpoint(ctx, del.EndpointID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err != nil || ep.Disabled {
+		del.Status = StatusFailed
+		del.NextAttempt = time.Time{}
+		return d.store.SaveDelivery(ctx, del)
+	}
+
+	a, err := d.attempt(ctx, ep, &del, false)
+	if err != nil {
+		return err
+	}
+	switch {
+	case a.Succeeded():
+		del.Status = StatusSucceeded
+		del.NextAttempt = time.Time{}
+	case del.Attempts >= d.maxAttempts:
+		d.logger.Warn("webhook: delivery failed", "delivery", del.ID, "endpoint", ep.ID, "attempts", del.Attempts, "error", a.Error)
+		del.Status = StatusFailed
+		del.NextAttempt = time.Time{}
+	default:
+		del.Status = StatusPending
+		del.NextAttempt = d.now().Add(d.backoff.NextDelay(del.Attempts))
+	}
+	return d.store.SaveDelivery(ctx, del)
+}
+
+// Redeliver sends a delivery again now, whatever its status, and returns
+// the attempt. It claims the delivery like the consumer does, and returns
+// ErrDeliveryBusy if another attempt holds it. A success marks the
+// delivery succeeded; a failure leaves its status and any retry schedule
+// as they were, except that a queued delivery is queued again. It returns
+// ErrEndpointDisabled if the endpoint is disabled.
+func (d *Dispatcher) Redeliver(ctx context.Context, deliveryID string) (Attempt, error) {
+	prev, err := d.store.Delivery(ctx, deliveryID)
+	if err != nil {
+		return Attempt{}, err
+	}
+	ep, err := d.store.Endpoint(ctx, prev.EndpointID)
+	if err != nil {
+		return Attempt{}, err
+	}
+	if ep.Disabled {
+		return Attempt{}, ErrEndpointDisabled
+	}
+	if prev.Status == StatusSending && prev.NextAttempt.After(d.now()) {
+		return Attempt{}, ErrDeliveryBusy
+	}
+
+	del, ok, err := d.store.Claim(ctx, prev.ID, prev.Status, prev.Attempts, d.now().Add(queueTimeout))
+	if err != nil {
+		return Attempt{}, err
+	}
+	if !ok {
+		return Attempt{}, ErrDeliveryBusy
+	}
+
+	a, err := d.attempt(ctx, ep, &del, true)
+	if err != nil {
+		return a, err
+	}
+	switch {
+	case a.Succeeded():
+		del.Status = StatusSucceeded
+		del.NextAttempt = time.Time{}
+	case prev.Status == StatusQueued || prev.Status == StatusSending:
+		// The queued message names the previous attempt and will be
+		// skipped, so the scheduler queues the delivery again.
+		del.Status = StatusPending
+		del.NextAttempt = d.now()
+	default:
+		del.Status = prev.Status
+		del.NextAttempt = prev.NextAttempt
+	}
+	return a, d.store.SaveDelivery(ctx, del)
+}
+
+// EnableEndpoint re-enables a disabled endpoint. Deliveries that failed
+// while it was disabled can be sent again with Redeliver.
+func (d *Dispatcher) EnableEndpoint(ctx context.Context, endpointID string) error {
+	d.endpointMu.Lock()
+	defer d.endpointMu.Unlock()
+
+	ep, err := d.store.Endpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	ep.Disabled = false
+	ep.DisabledAt = time.Time{}
+	ep.FailingSince = time.Time{}
+	return d.store.SaveEndpoint(ctx, ep)
+}
+
+// attempt POSTs del to ep, counts and records the attempt, and updates
+// the endpoint's health. Only store errors are returned.
+func (d *Dispatcher) attempt(ctx context.Context, ep Endpoint, del *Delivery, manual bool) (Attempt, error) {
+	del.Attempts++
+	at := d.now()
+	a := Attempt{DeliveryID: del.ID, Number: del.Attempts, At: at, Manual: manual}
+
+	start := time.Now()
+	resp, err := d.client.Post(ctx, ep.URL, del.Payload,
+		network.WithHeader("Content-Type", "application/json"),
+		network.WithHeader(HeaderID, del.ID),
+		network.WithHeader(HeaderTimestamp, strconv.FormatInt(at.Unix(), 10)),
+		network.WithHeader(HeaderSignature, Sign(ep.Secret, del.ID, at, del.Payload)),
+		network.WithHeader(HeaderEvent, del.EventType),
+	)
+	a.Duration = time.Since(start)
+	switch {
+	case err != nil:
+		a.Error = err.Error()
+	default:
+		a.StatusCode = resp.StatusCode
+		a.Response = resp.Body[:min(len(resp.Body), 1<<10)]
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			a.Error = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+	}
+
+	if err := d.store.AddAttempt(ctx, a); err != nil {
+		return a, fmt.Errorf("webhook: record attempt: %w", err)
+	}
+	return a, d.updateHealth(ctx, ep.ID, a.Succeeded())
+}
+
+// updateHealth tracks the endpoint's run of failures and disables it once
+// the run has lasted disableAfter.
+func (d *Dispatcher) updateHealth(ctx context.Context, endpointID string, ok bool) error {
+	d.endpointMu.Lock()
+	defer d.endpointMu.Unlock()
+
+	ep, err := d.store.Endpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	now := d.now()
+	switch {
+	case ok:
+		if ep.FailingSince.IsZero() {
+			return nil
+		}
+		ep.FailingSince = time.Time{}
+	case ep.FailingSince.IsZero():
+		ep.FailingSince = now
+	case !ep.Disabled && now.Sub(ep.FailingSince) >= d.disableAfter:
+		d.logger.Warn("webhook: disabling endpoint", "endpoint", ep.ID, "failing_since", ep.FailingSince)
+		ep.Disabled = true
+		ep.DisabledAt = now
+	default:
+		return nil
+	}
+	return d.store.SaveEndpoint(ctx, ep)
+}
+
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return "msg_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,295 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/festus/microkit/adapters/memory"
+	"github.com/festus/microkit/messaging"
+	"github.com/festus/microkit/retry"
+)
+
+// receiver is a customer endpoint that fails its first failures requests
+// with 500.
+type receiver struct {
+	*httptest.Server
+	failures atomic.Int32
+	requests atomic.Int32
+	verified atomic.Int32
+}
+
+func newReceiver(t *testing.T, secret []byte, failures int) *receiver {
+	t.Helper()
+	r := &receiver{}
+	r.failures.Store(int32(failures))
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests.Add(1)
+		body, _ := io.ReadAll(req.Body)
+		if Verify(secret, req.Header, body, time.Minute) == nil && req.Header.Get(HeaderEvent) == "order.created" {
+			r.verified.Add(1)
+		}
+		if r.failures.Add(-1) >= 0 {
+			http.Error(w, "down for maintenance", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func newDispatcher(t *testing.T, store Store, opts ...Option) *Dispatcher {
+	t.Helper()
+	broker := memory.NewBroker(messaging.DefaultConfig())
+	t.Cleanup(func() { broker.Close() })
+
+	opts = append([]Option{WithBackoff(retry.Constant{Delay: 10 * time.Millisecond}), WithPollInterval(5 * time.Millisecond)}, opts...)
+	d := New(broker, broker, store, opts...)
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+	return d
+}
+
+// waitStatus waits for a delivery to reach status.
+func waitStatus(t *testing.T, store Store, id string, status Status) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		del, err := store.Delivery(context.Background(), id)
+		if err == nil && del.Status == status {
+			return del
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Delivery %s is %s, expected %s", id, del.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverRetriesUntilSuccess(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec")
+	recv := newReceiver(t, secret, 2)
+	store := NewMemoryStore()
+	store.SaveEndpoint(ctx, Endpoint{ID: "acme", URL: recv.URL, Secret: secret})
+	d := newDispatcher(t, store)
+
+	id, err := d.Send(ctx, "acme", "order.created", []byte(`{"id":1}`))
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	del := waitStatus(t, store, id, StatusSucceeded)
+	if del.Attempts != 3 || recv.verified.Load() != 3 {
+		t.Fatalf("Expected 3 signed attempts, got %d attempts and %d verified", del.Attempts, recv.verified.Load())
+	}
+
+	attempts, _ := store.Attempts(ctx, id)
+	if len(attempts) != 3 || attempts[0].StatusCode != 500 || attempts[0].Succeeded() || !attempts[2].Succeeded() {
+		t.Fatalf("Unexpected attempts %+v", attempts)
+	}
+	if string(attempts[0].Response) != "down for maintenance\n" {
+		t.Fatalf("Expected the response body to be recorded, got %q", attempts[0].Response)
+	}
+	if ep, _ := store.Endpoint(ctx, "acme"); !ep.FailingSince.IsZero() {
+		t.Fatalf("Expected the endpoint to be healthy again, failing since %v", ep.FailingSince)
+	}
+}
+
+func TestDeliverDisablesFailingEndpoint(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec")
+	recv := newReceiver(t, secret, 1000)
+	store := NewMemoryStore()
+	store.SaveEndpoint(ctx, Endpoint{ID: "acme", URL: recv.URL, Secret: secret})
+	// The second failure in a row disables the endpoint, which fails the
+	// delivery before its retries run out.
+	d := newDispatcher(t, store, WithMaxAttempts(5), WithDisableAfter(0))
+
+	id, _ := d.Send(ctx, "acme", "order.created", []byte(`{"id":1}`))
+	if del := waitStatus(t, store, id, StatusFailed); del.Attempts != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", del.Attempts)
+	}
+
+	ep, _ := store.Endpoint(ctx, "acme")
+	if !ep.Disabled || ep.DisabledAt.IsZero() {
+		t.Fatalf("Expected the endpoint to be disabled, got %+v", ep)
+	}
+	if _, err := d.Send(ctx, "acme", "order.created", nil); !errors.Is(err, ErrEndpointDisabled) {
+		t.Fatalf("Expected ErrEndpointDisabled, got %v", err)
+	}
+	if _, err := d.Redeliver(ctx, id); !errors.Is(err, ErrEndpointDisabled) {
+		t.Fatalf("Expected ErrEndpointDisabled, got %v", err)
+	}
+
+	// Once the customer fixes their endpoint, the failed delivery can be
+	// sent again by hand.
+	recv.failures.Store(0)
+	if err := d.EnableEndpoint(ctx, "acme"); err != nil {
+		t.Fatalf("EnableEndpoint failed: %v", err)
+	}
+	a, err := d.Redeliver(ctx, id)
+	if err != nil || !a.Succeeded() || !a.Manual || a.Number != 3 {
+		t.Fatalf("Expected a successful manual third attempt, got %+v, %v", a, err)
+	}
+	if del, _ := store.Delivery(ctx, id); del.Status != StatusSucceeded {
+		t.Fatalf("Expected the delivery to succeed, got %s", del.Status)
+	}
+}
+
+func TestSchedulerRequeuesAfterPublishFailure(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec")
+	recv := newReceiver(t, secret, 0)
+	store := NewMemoryStore()
+	store.SaveEndpoint(ctx, Endpoint{ID: "acme", URL: recv.URL, Secret: secret})
+
+	broker := memory.NewBroker(messaging.DefaultConfig())
+	defer broker.Close()
+	producer := &flakyProducer{Producer: broker}
+	producer.fail.Store(true)
+	d := New(producer, broker, store, WithPollInterval(5*time.Millisecond))
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer d.Shutdown(ctx)
+
+	id, err := d.Send(ctx, "acme", "order.created", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if del, _ := store.Delivery(ctx, id); del.Status != StatusPending {
+		t.Fatalf("Expected the delivery to wait for the scheduler, got %s", del.Status)
+	}
+	producer.fail.Store(false)
+	waitStatus(t, store, id, StatusSucceeded)
+}
+
+func TestDuplicateMessagesDeliverOnce(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("whsec")
+	recv := newReceiver(t, secret, 0)
+	store := NewMemoryStore()
+	store.SaveEndpoint(ctx, Endpoint{ID: "acme", URL: recv.URL, Secret: secret})
+
+	broker := memory.NewBroker(messaging.DefaultConfig())
+	defer broker.Close()
+	d := New(broker, broker, store, WithPollInterval(time.Hour))
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer d.Shutdown(ctx)
+
+	// The delivery was queued again after a failed first attempt. A copy
+	// of the first message is still around, and the broker delivers the
+	// new one twice.
+	del := Delivery{ID: "msg_1", EndpointID: "acme", EventType: "order.created", Payload: []byte(`{}`), Status: StatusQueued, Attempts: 1}
+	store.SaveDelivery(ctx, del)
+	stale := deliveryMessage(Delivery{ID: del.ID, EndpointID: del.EndpointID})
+	for _, msg := range []messaging.Message{stale, deliveryMessage(del), deliveryMessage(del)} {
+		if err := broker.Publish(ctx, "webhooks", msg); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	waitStatus(t, store, del.ID, StatusSucceeded)
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := store.Delivery(ctx, del.ID); recv.requests.Load() != 1 || got.Attempts != 2 {
+		t.Fatalf("Expected exactly one POST, got %d requests and %d attempts", recv.requests.Load(), got.Attempts)
+	}
+}
+
+func TestRedeliverClaimsDelivery(t *testing.T) {
+	ctx := context.Background()
+	var requests atomic.Int32
+	arrived, release := make(chan struct{}), make(chan struct{})
+	recv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if requests.Add(1) == 1 {
+			close(arrived)
+			<-release
+		}
+	}))
+	defer recv.Close()
+	store := NewMemoryStore()
+	store.SaveEndpoint(ctx, Endpoint{ID: "acme", URL: recv.URL, Secret: []byte("whsec")})
+
+	broker := memory.NewBroker(messaging.DefaultConfig())
+	defer broker.Close()
+	d := New(broker, broker, store, WithPollInterval(time.Hour))
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer d.Shutdown(ctx)
+
+	del := Delivery{ID: "msg_1", EndpointID: "acme", EventType: "order.created", Payload: []byte(`{}`), Status: StatusQueued, Attempts: 1}
+	store.SaveDelivery(ctx, del)
+
+	// The scheduled retry arrives while Redeliver is sending, and a second
+	// Redeliver is refused.
+	result := make(chan error, 1)
+	go func() {
+		_, err := d.Redeliver(ctx, del.ID)
+		result <- err
+	}()
+	<-arrived
+	if err := broker.Publish(ctx, "webhooks", deliveryMessage(del)); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if _, err := d.Redeliver(ctx, del.ID); !errors.Is(err, ErrDeliveryBusy) {
+		t.Fatalf("Expected ErrDeliveryBusy, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if err := <-result; err != nil {
+		t.Fatalf("Redeliver failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := store.Delivery(ctx, del.ID); requests.Load() != 1 || got.Attempts != 2 || got.Status != StatusSucceeded {
+		t.Fatalf("Expected exactly one successful POST, got %d requests and %+v", requests.Load(), got)
+	}
+}
+
+type flakyProducer struct {
+	messaging.Producer
+	fail atomic.Bool
+}
+
+func (p *flakyProducer) Publish(ctx context.Context, topic string, msg messaging.Message) error {
+	if p.fail.Load() {
+		return errors.New("broker unavailable")
+	}
+	return p.Producer.Publish(ctx, topic, msg)
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("whsec")
+	payload := []byte(`{"id":1}`)
+	now := time.Now()
+	header := func(ts time.Time, sig string) http.Header {
+		return http.Header{
+			HeaderID:        {"msg_1"},
+			HeaderTimestamp: {strconv.FormatInt(ts.Unix(), 10)},
+			HeaderSignature: {sig},
+		}
+	}
+
+	// Senders rotating secrets sign with both; one match is enough.
+	rotating := Sign([]byte("old"), "msg_1", now, payload) + " " + Sign(secret, "msg_1", now, payload)
+	if err := Verify(secret, header(now, rotating), payload, time.Minute); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := Verify(secret, header(now, Sign(secret, "msg_1", now, payload)), []byte(`{"id":2}`), time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected ErrInvalidSignature for a changed payload, got %v", err)
+	}
+	old := now.Add(-time.Hour)
+	if err := Verify(secret, header(old, Sign(secret, "msg_1", old, payload)), payload, time.Minute); !errors.Is(err, ErrTimestampSkew) {
+		t.Fatalf("Expected ErrTimestampSkew, got %v", err)
+	}
+}